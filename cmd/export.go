@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
@@ -12,86 +13,260 @@ import (
 var (
 	exportDBPath   string
 	exportOutput   string
-	exportStart    string
-	exportEnd      string
-	exportFileType string
+	exportFilter   filterOptions
 	exportDir      string
 	exportLimit    int
+	exportTemplate string
+	exportTheme    string
+	exportTitle    string
+	exportLogo     string
+	exportFormat   string
+	exportIdle     time.Duration
+	exportDepth    int
+	exportRoot     string
 )
 
 var exportCmd = &cobra.Command{
-	Use:   "export",
-	Short: "Export timeline to HTML",
-	Long:  `Export file system timeline events to an HTML file.`,
-	RunE:  runExport,
+	Use:   "export [expression]",
+	Short: "Export timeline to HTML, JSON, CSV, Markdown, iCalendar or forensic formats",
+	Long: `Export file system timeline events to an HTML report or a JSON, NDJSON, CSV,
+TSV or Markdown file, a Sleuthkit body file for mactime, a log2timeline L2T
+CSV file, ECS documents or OTLP log records for a log collector, or an
+iCalendar file of activity sessions per project. The format is taken from
+--format, or else from the extension of the output file. An optional
+expression filters events as in "fstimeline query".`,
+	RunE: runExport,
 }
 
 func init() {
-	exportCmd.Flags().StringVarP(&exportDBPath, "db", "d", "fstimeline.db", "Database path")
-	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "timeline.html", "Output HTML file")
-	exportCmd.Flags().StringVarP(&exportStart, "start", "s", "", "Start time (RFC3339 format or relative like -24h)")
-	exportCmd.Flags().StringVarP(&exportEnd, "end", "e", "", "End time (RFC3339 format)")
-	exportCmd.Flags().StringVarP(&exportFileType, "type", "t", "", "Filter by file type")
+	exportCmd.Flags().StringVarP(&exportDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "timeline.html", "Output file")
+	exportFilter.addFlags(exportCmd.Flags())
 	exportCmd.Flags().StringVarP(&exportDir, "dir", "D", "", "Filter by directory")
-	exportCmd.Flags().IntVarP(&exportLimit, "limit", "l", 1000, "Limit number of results")
+	exportCmd.Flags().IntVarP(&exportLimit, "limit", "l", 0, "Limit to the most recent N events (0 exports all)")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "", "Export format: html, json, ndjson, csv, tsv, markdown, bodyfile, l2tcsv, ecs, otlp or ics (default: from the output extension, else html)")
+	exportCmd.Flags().StringVar(&exportTemplate, "template", "", "Render with this html/template file instead of the built-in report")
+	exportCmd.Flags().StringVar(&exportTheme, "theme", "light", "Report theme: light, dark or print")
+	exportCmd.Flags().StringVar(&exportTitle, "title", "", "Report or calendar title (default: File System Timeline)")
+	exportCmd.Flags().StringVar(&exportLogo, "logo", "", "Image file to embed in the report header")
+	exportCmd.Flags().DurationVar(&exportIdle, "idle", export.DefaultIdleGap, "Idle gap that ends an activity session (ics format)")
+	exportCmd.Flags().IntVar(&exportDepth, "project-depth", 1, "Directory levels below the project root that make up a project (ics format)")
+	exportCmd.Flags().StringVar(&exportRoot, "project-root", "", "Directory holding the projects (ics format; default: --dir, else the directory common to all events)")
 }
 
 func runExport(cmd *cobra.Command, args []string) error {
 	// Open database
-	db, err := database.New(exportDBPath)
+	db, err := database.OpenReadOnly(exportDBPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	// Parse time filters
+	// Build the filter
 	filter := database.QueryFilter{
-		FileType:  exportFileType,
 		Directory: exportDir,
 		Limit:     exportLimit,
 	}
 
-	if exportStart != "" {
-		startTime, err := parseTime(exportStart)
-		if err != nil {
-			return fmt.Errorf("invalid start time: %w", err)
+	if err := exportFilter.apply(&filter, args); err != nil {
+		return err
+	}
+
+	// Create exporter
+	format := exportFormat
+	if format == "" {
+		format = export.FormatForPath(exportOutput)
+	}
+	exporter, err := export.NewExporter(format)
+	if err != nil {
+		return fmt.Errorf("failed to create exporter: %w", err)
+	}
+
+	if err := onlyFor(cmd, format, "html", "template", "theme", "logo"); err != nil {
+		return err
+	}
+	if err := onlyFor(cmd, format, "ics", "idle", "project-depth", "project-root"); err != nil {
+		return err
+	}
+	if format != "html" && format != "ics" && cmd.Flags().Changed("title") {
+		return fmt.Errorf("--title only applies to the html and ics formats")
+	}
+
+	switch exporter := exporter.(type) {
+	case *export.HTMLExporter:
+		if err := configureHTML(exporter); err != nil {
+			return err
+		}
+	case *export.ICSExporter:
+		if err := configureICS(exporter); err != nil {
+			return err
+		}
+	}
+
+	total, err := exportStream(db, filter, exporter)
+	if err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+
+	fmt.Printf("✅ Timeline exported to: %s\n", exportOutput)
+	fmt.Printf("📊 Total events: %d\n", total)
+
+	return nil
+}
+
+// configureHTML applies the report options to the HTML exporter.
+func configureHTML(exporter *export.HTMLExporter) error {
+	if err := exporter.SetTheme(exportTheme); err != nil {
+		return err
+	}
+	if exportTitle != "" {
+		exporter.SetTitle(exportTitle)
+	}
+	if exportLogo != "" {
+		if err := exporter.SetLogo(exportLogo); err != nil {
+			return err
+		}
+	}
+	if exportTemplate != "" {
+		if err := exporter.SetTemplate(exportTemplate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configureICS applies the session options to the calendar exporter.
+func configureICS(exporter *export.ICSExporter) error {
+	if err := exporter.SetIdleGap(exportIdle); err != nil {
+		return err
+	}
+	if err := exporter.SetProjectDepth(exportDepth); err != nil {
+		return err
+	}
+	if exportTitle != "" {
+		exporter.SetTitle(exportTitle)
+	}
+
+	root := exportRoot
+	if root == "" {
+		root = exportDir
+	}
+	exporter.SetProjectRoot(root)
+
+	return nil
+}
+
+// onlyFor rejects the named flags unless the format is format.
+func onlyFor(cmd *cobra.Command, format, only string, names ...string) error {
+	if format == only {
+		return nil
+	}
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s only applies to the %s format", name, only)
 		}
-		filter.StartTime = &startTime
 	}
+	return nil
+}
 
-	if exportEnd != "" {
-		endTime, err := time.Parse(time.RFC3339, exportEnd)
+// exportStream writes the matching events oldest first. Without a limit the
+// events are streamed straight from the database; with one, the newest page
+// is loaded and reversed. The HTML report is also given its charts.
+func exportStream(db database.Store, filter database.QueryFilter, exporter export.Exporter) (int, error) {
+	html, _ := exporter.(*export.HTMLExporter)
+
+	// Count, chart and export the same events while a watcher keeps writing
+	latestID, err := db.LatestEventID()
+	if err != nil {
+		return 0, err
+	}
+	if latestID == 0 {
+		return 0, exporter.Export(nil, exportOutput)
+	}
+	filter.UpToID = latestID
+
+	if filter.Limit > 0 {
+		events, err := db.QueryEvents(filter)
 		if err != nil {
-			return fmt.Errorf("invalid end time: %w", err)
+			return 0, fmt.Errorf("failed to query events: %w", err)
+		}
+
+		// Reverse to show oldest first
+		slices.Reverse(events)
+
+		// Chart only the exported page
+		if html != nil && len(events) > 0 {
+			chartFilter := filter
+			chartFilter.StartTime = &events[0].Timestamp
+			stats, err := db.Stats(chartFilter, chartTop)
+			if err != nil {
+				return 0, err
+			}
+			if err := setCharts(db, chartFilter, stats, html); err != nil {
+				return 0, err
+			}
 		}
-		filter.EndTime = &endTime
+
+		return len(events), exporter.Export(events, exportOutput)
 	}
 
-	// Query events
-	events, err := db.QueryEvents(filter)
+	top := 0
+	if html != nil {
+		top = chartTop
+	}
+	stats, err := db.Stats(filter, top)
 	if err != nil {
-		return fmt.Errorf("failed to query events: %w", err)
+		return 0, err
 	}
 
-	// Reverse to show oldest first
-	for i := len(events)/2 - 1; i >= 0; i-- {
-		opp := len(events) - 1 - i
-		events[i], events[opp] = events[opp], events[i]
+	if html != nil && stats.TotalEvents > 0 {
+		if err := setCharts(db, filter, stats, html); err != nil {
+			return 0, err
+		}
 	}
 
-	// Create exporter
-	exporter, err := export.NewHTMLExporter()
+	// PostgreSQL can still commit an event below latestID after the count,
+	// so report how many were actually written
+	filter.Ascending = true
+	written := 0
+	source := func(fn func(*database.Event) error) error {
+		return db.IterateEvents(filter, func(event *database.Event) error {
+			written++
+			return fn(event)
+		})
+	}
+
+	if err := exporter.ExportStream(source, int(stats.TotalEvents), exportOutput); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// chartTop is how many directories and files the export charts.
+const chartTop = 10
+
+// setCharts gives the exporter the charts of the events matching filter:
+// activity split by event type, a calendar of daily activity, and the
+// busiest directories and files from stats.
+func setCharts(db database.Store, filter database.QueryFilter, stats *database.Stats, exporter *export.HTMLExporter) error {
+	interval, err := autoInterval(db, filter)
 	if err != nil {
-		return fmt.Errorf("failed to create exporter: %w", err)
+		return err
 	}
 
-	// Export to HTML
-	if err := exporter.Export(events, exportOutput); err != nil {
-		return fmt.Errorf("failed to export: %w", err)
+	histogram, err := db.Histogram(filter, database.HistogramOptions{Interval: interval, GroupBy: "type"})
+	if err != nil {
+		return err
 	}
+	exporter.SetHistogram(histogram)
 
-	fmt.Printf("✅ Timeline exported to: %s\n", exportOutput)
-	fmt.Printf("📊 Total events: %d\n", len(events))
+	daily, err := db.Histogram(filter, database.HistogramOptions{Interval: database.IntervalDay})
+	if err != nil {
+		return err
+	}
+	exporter.SetCalendar(daily)
+	exporter.SetStats(stats)
 
 	return nil
 }
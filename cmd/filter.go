@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/query"
+	"github.com/spf13/pflag"
+)
+
+// filterOptions holds the event-matching flags shared by commands that read
+// events.
+type filterOptions struct {
+	start             string
+	end               string
+	fileTypes         []string
+	eventTypes        []string
+	excludeEventTypes []string
+	pathGlob          string
+	pathRegex         string
+	nameContains      string
+	excludeDirs       []string
+}
+
+func (o *filterOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.start, "start", "s", "", "Start time (e.g., 2025-12-19, yesterday, -24h, -3d)")
+	flags.StringVarP(&o.end, "end", "e", "", "End time (same formats as --start; a date, today or yesterday includes the whole day)")
+	flags.StringSliceVarP(&o.fileTypes, "type", "t", nil, "Filter by file type (e.g., 'go', 'txt'); repeat or comma-separate for several")
+	flags.StringSliceVarP(&o.eventTypes, "event", "E", nil, "Only these event types (CREATE, WRITE, REMOVE, RENAME, CHMOD)")
+	flags.StringSliceVar(&o.excludeEventTypes, "exclude-event", nil, "Exclude these event types")
+	flags.StringVarP(&o.pathGlob, "glob", "g", "", "Filter by path glob (e.g., '*/src/*.go')")
+	flags.StringVarP(&o.pathRegex, "regex", "r", "", "Filter by path regular expression")
+	flags.StringVar(&o.nameContains, "name", "", "Filter by substring of the file name")
+	flags.StringSliceVar(&o.excludeDirs, "exclude-dir", nil, "Exclude events under these directories")
+}
+
+// apply copies the flags into filter. args, if any, are joined into a query
+// language expression.
+func (o *filterOptions) apply(filter *database.QueryFilter, args []string) error {
+	if o.start != "" {
+		startTime, err := parseTime(o.start)
+		if err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+		filter.StartTime = &startTime
+	}
+
+	if o.end != "" {
+		endTime, err := parseEnd(o.end)
+		if err != nil {
+			return fmt.Errorf("invalid end time: %w", err)
+		}
+		filter.EndTime = &endTime
+	}
+
+	if len(args) > 0 {
+		expression, err := query.Parse(strings.Join(args, " "), parseTime)
+		if err != nil {
+			return err
+		}
+		filter.Expression = expression
+	}
+
+	filter.FileTypes = o.fileTypes
+	filter.EventTypes = upper(o.eventTypes)
+	filter.ExcludeEventTypes = upper(o.excludeEventTypes)
+	filter.PathGlob = o.pathGlob
+	filter.PathRegex = o.pathRegex
+	filter.NameContains = o.nameContains
+	filter.ExcludeDirectories = o.excludeDirs
+
+	return filter.Validate()
+}
+
+func upper(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToUpper(value)
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/timeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	histogramDBPath    string
+	histogramFilter    filterOptions
+	histogramDir       string
+	histogramInterval  string
+	histogramBy        string
+	histogramTop       int
+	histogramSparkline bool
+	histogramFormat    string
+	histogramNoColor   bool
+)
+
+var histogramCmd = &cobra.Command{
+	Use:   "histogram [expression]",
+	Short: "Chart activity over time",
+	Long: `Count events per minute, hour, day or week and draw them as a bar chart or
+sparklines, optionally split by event type or directory. Accepts the same
+filters and expression language as query.`,
+	RunE: runHistogram,
+}
+
+func init() {
+	histogramCmd.Flags().StringVarP(&histogramDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	histogramFilter.addFlags(histogramCmd.Flags())
+	histogramCmd.Flags().StringVarP(&histogramDir, "dir", "D", "", "Filter by directory")
+	histogramCmd.Flags().StringVarP(&histogramInterval, "interval", "i", "auto", "Bucket size: minute, hour, day, week or auto")
+	histogramCmd.Flags().StringVar(&histogramBy, "by", "", "Split counts by event type or directory (type, dir)")
+	histogramCmd.Flags().IntVar(&histogramTop, "top", 5, "Number of groups to show with --by; the rest are counted as other")
+	histogramCmd.Flags().BoolVar(&histogramSparkline, "sparkline", false, "Draw one compact line per group")
+	histogramCmd.Flags().StringVarP(&histogramFormat, "format", "f", "text", "Output format: text or json")
+	histogramCmd.Flags().BoolVarP(&histogramNoColor, "no-color", "n", false, "Disable colored output")
+}
+
+func runHistogram(cmd *cobra.Command, args []string) error {
+	if histogramFormat != "text" && histogramFormat != "json" {
+		return fmt.Errorf("unknown format %q (use text or json)", histogramFormat)
+	}
+
+	// Open database
+	db, err := database.OpenReadOnly(histogramDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	filter := database.QueryFilter{Directory: histogramDir}
+
+	if err := histogramFilter.apply(&filter, args); err != nil {
+		return err
+	}
+
+	options := database.HistogramOptions{GroupBy: histogramBy, Top: histogramTop}
+	if histogramInterval == "auto" {
+		options.Interval, err = autoInterval(db, filter)
+	} else {
+		options.Interval, err = database.ParseInterval(histogramInterval)
+	}
+	if err != nil {
+		return err
+	}
+
+	histogram, err := db.Histogram(filter, options)
+	if err != nil {
+		return err
+	}
+
+	if histogramFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(histogram)
+	}
+
+	renderer := timeline.NewRenderer(!histogramNoColor)
+	if histogramSparkline {
+		fmt.Print(renderer.RenderSparklines(histogram))
+	} else {
+		fmt.Print(renderer.RenderHistogram(histogram))
+	}
+
+	return nil
+}
+
+// autoInterval picks a bucket size from the time span of the matching events.
+func autoInterval(db database.Store, filter database.QueryFilter) (database.Interval, error) {
+	stats, err := db.Stats(filter, 0)
+	if err != nil {
+		return "", err
+	}
+	if stats.TotalEvents == 0 {
+		return database.IntervalHour, nil
+	}
+	return database.IntervalFor(stats.LastEvent.Sub(*stats.FirstEvent)), nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/history"
+	"github.com/BaseMax/go-fs-timeline/pkg/timeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyDBPath  string
+	historyWindow  time.Duration
+	historyNoColor bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <path>",
+	Short: "Show the lifecycle of one file",
+	Long: `Show everything that happened to one file: its creation, writes (coalesced),
+permission changes and deletion, following it across renames in both
+directions, with the time between events and its size over time.
+
+The path is matched as recorded by the watcher, so pass it the way it was
+watched (relative or absolute).`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	historyCmd.Flags().DurationVarP(&historyWindow, "window", "w", time.Second, "Maximum time between a rename and the matching create")
+	historyCmd.Flags().BoolVarP(&historyNoColor, "no-color", "n", false, "Disable colored output")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	// Open database
+	db, err := database.OpenReadOnly(historyDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	path := filepath.Clean(args[0])
+	steps, err := history.Trace(db, path, historyWindow)
+	if err != nil {
+		return err
+	}
+
+	// Watching an absolute path records absolute paths
+	if len(steps) == 0 && !filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			if steps, err = history.Trace(db, abs, historyWindow); err != nil {
+				return err
+			}
+			if len(steps) > 0 {
+				path = abs
+			}
+		}
+	}
+
+	renderer := timeline.NewRenderer(!historyNoColor)
+	fmt.Print(renderer.RenderHistory(path, steps))
+
+	return nil
+}
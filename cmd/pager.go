@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// errPagerClosed reports that the user quit the pager before all output was
+// written; the command stops quietly.
+var errPagerClosed = errors.New("pager closed")
+
+// pager pipes output through $PAGER (less by default) when stdout is a
+// terminal. less is told to exit straight away if the output fits on one
+// screen and to pass colors through.
+type pager struct {
+	cmd  *exec.Cmd
+	pipe io.WriteCloser
+}
+
+// startPager starts the pager, or returns nil when output should go straight
+// to stdout: it is not a terminal, or PAGER is empty or "cat".
+func startPager() (*pager, error) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, nil
+	}
+
+	command, ok := os.LookupEnv("PAGER")
+	if !ok {
+		command = "less"
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 || args[0] == "cat" {
+		return nil, nil
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start pager: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		// A missing pager is not worth failing the command over
+		return nil, nil
+	}
+
+	return &pager{cmd: cmd, pipe: pipe}, nil
+}
+
+func (p *pager) Write(data []byte) (int, error) {
+	n, err := p.pipe.Write(data)
+	if errors.Is(err, syscall.EPIPE) {
+		return n, errPagerClosed
+	}
+	return n, err
+}
+
+// done ends the output and waits for the user to quit the pager. It passes
+// on err from the command, unless the pager was quit early.
+func (p *pager) done(err error) error {
+	p.pipe.Close()
+	p.cmd.Wait()
+
+	if errors.Is(err, errPagerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDBPath string
+	pruneBefore string
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old events",
+	Long:  `Delete events recorded before a given time to keep the database small.`,
+	RunE:  runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().StringVarP(&pruneDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	pruneCmd.Flags().StringVarP(&pruneBefore, "before", "b", "", "Delete events before this time (e.g., 2025-12-01, -30d)")
+	pruneCmd.MarkFlagRequired("before")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	before, err := parseTime(pruneBefore)
+	if err != nil {
+		return fmt.Errorf("invalid before time: %w", err)
+	}
+
+	// Open database
+	db, err := database.Open(pruneDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	count, err := db.Prune(before)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🧹 Deleted %d events before %s\n", count, before.Format("2006-01-02 15:04:05"))
+
+	return nil
+}
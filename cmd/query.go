@@ -1,105 +1,323 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/export"
 	"github.com/BaseMax/go-fs-timeline/pkg/timeline"
+	"github.com/BaseMax/go-fs-timeline/pkg/timespec"
 	"github.com/spf13/cobra"
 )
 
 var (
-	queryDBPath   string
-	queryStart    string
-	queryEnd      string
-	queryFileType string
-	queryDir      string
-	queryLimit    int
-	queryNoColor  bool
+	queryDBPath  string
+	queryFilter  filterOptions
+	queryDir     string
+	queryLimit   int
+	queryNoColor bool
+	queryAfter   string
+	queryBefore  string
+	queryFormat  string
+	queryFollow  bool
+	queryPoll    time.Duration
+	queryTree    bool
+	queryRoot    string
+	queryBucket  string
+	queryAgo     bool
+	queryNoPager bool
 )
 
 var queryCmd = &cobra.Command{
-	Use:   "query",
+	Use:   "query [expression]",
 	Short: "Query file system events",
-	Long:  `Query and display file system events with various filters.`,
-	RunE:  runQuery,
+	Long: `Query and display file system events with various filters.
+
+An optional expression narrows the results further, for example:
+
+  fstimeline query 'type:go AND event:WRITE AND path:~"internal/" AND time > -2h AND size > 1MB'
+
+Fields are type, event, path, name, dir, time and size. "field:value" matches
+a file type or event type exactly, a path or name by substring or glob, and a
+directory by prefix; "field:~value" matches a regular expression. time and
+size take = != < <= > >=. Combine conditions with AND, OR, NOT and parentheses.`,
+	RunE: runQuery,
 }
 
 func init() {
-	queryCmd.Flags().StringVarP(&queryDBPath, "db", "d", "fstimeline.db", "Database path")
-	queryCmd.Flags().StringVarP(&queryStart, "start", "s", "", "Start time (RFC3339 format or relative like -24h)")
-	queryCmd.Flags().StringVarP(&queryEnd, "end", "e", "", "End time (RFC3339 format)")
-	queryCmd.Flags().StringVarP(&queryFileType, "type", "t", "", "Filter by file type (e.g., 'go', 'txt')")
+	queryCmd.Flags().StringVarP(&queryDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	queryFilter.addFlags(queryCmd.Flags())
 	queryCmd.Flags().StringVarP(&queryDir, "dir", "D", "", "Filter by directory")
-	queryCmd.Flags().IntVarP(&queryLimit, "limit", "l", 100, "Limit number of results")
+	queryCmd.Flags().IntVarP(&queryLimit, "limit", "l", 100, "Limit number of results (0 streams all matching events)")
+	queryCmd.Flags().StringVar(&queryAfter, "after", "", "Show the page of events after this cursor")
+	queryCmd.Flags().StringVar(&queryBefore, "before", "", "Show the page of events before this cursor")
 	queryCmd.Flags().BoolVarP(&queryNoColor, "no-color", "n", false, "Disable colored output")
+	queryCmd.Flags().BoolVar(&queryAgo, "ago", false, "Show event times relative to now, such as 3m ago")
+	queryCmd.Flags().BoolVar(&queryNoPager, "no-pager", false, "Write straight to stdout instead of through $PAGER")
+	queryCmd.Flags().BoolVarP(&queryFollow, "follow", "F", false, "Keep running and print new events as they are written")
+	queryCmd.Flags().DurationVar(&queryPoll, "poll", time.Second, "How often to check for new events with --follow")
+	queryCmd.Flags().BoolVarP(&queryTree, "tree", "T", false, "Group each bucket's events by directory as a tree (text format)")
+	queryCmd.Flags().StringVar(&queryRoot, "root", "", "Show tree paths relative to this directory (default: the watched directory, --dir)")
+	queryCmd.Flags().StringVar(&queryBucket, "bucket", "day", "Tree grouping interval: minute, hour, day or week")
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "text", "Output format: text, json, ndjson, csv, tsv, markdown, bodyfile, l2tcsv, ecs, otlp or template='{{.Timestamp}} {{.FilePath}}'")
 }
 
-func runQuery(cmd *cobra.Command, args []string) error {
+func runQuery(cmd *cobra.Command, args []string) (err error) {
+	if queryFollow && queryPoll <= 0 {
+		return fmt.Errorf("--poll must be positive, got %s", queryPoll)
+	}
+
 	// Open database
-	db, err := database.New(queryDBPath)
+	db, err := database.OpenReadOnly(queryDBPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	// Parse time filters
+	// Build the filter
 	filter := database.QueryFilter{
-		FileType:  queryFileType,
 		Directory: queryDir,
 		Limit:     queryLimit,
 	}
 
-	if queryStart != "" {
-		startTime, err := parseTime(queryStart)
+	if err := queryFilter.apply(&filter, args); err != nil {
+		return err
+	}
+
+	if queryAfter != "" {
+		filter.After, err = database.ParseCursor(queryAfter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if queryBefore != "" {
+		filter.Before, err = database.ParseCursor(queryBefore)
 		if err != nil {
-			return fmt.Errorf("invalid start time: %w", err)
+			return err
+		}
+	}
+
+	// Page long output, except when following new events
+	var stdout io.Writer = os.Stdout
+	if !queryFollow && !queryNoPager {
+		p, pagerErr := startPager()
+		if pagerErr != nil {
+			return pagerErr
+		}
+		if p != nil {
+			stdout = p
+			defer func() { err = p.done(err) }()
 		}
-		filter.StartTime = &startTime
 	}
 
-	if queryEnd != "" {
-		endTime, err := time.Parse(time.RFC3339, queryEnd)
+	var out export.EventWriter
+	if queryFormat == "text" {
+		renderer := timeline.NewRenderer(!queryNoColor)
+		renderer.SetRelativeTime(queryAgo)
+		if queryTree {
+			interval, err := database.ParseInterval(queryBucket)
+			if err != nil {
+				return err
+			}
+			root := queryRoot
+			if root == "" {
+				root = queryDir
+			}
+			renderer.SetTree(root, interval)
+		}
+		out = renderer.NewStream(stdout)
+	} else {
+		out, err = export.NewEventWriter(queryFormat, stdout)
 		if err != nil {
-			return fmt.Errorf("invalid end time: %w", err)
+			return err
 		}
-		filter.EndTime = &endTime
 	}
 
+	// Remember which events history covers so --follow prints exactly the
+	// ones committed after it
+	var seen map[int64]bool
+	var lastID int64
+	if queryFollow {
+		if seen, lastID, err = followStart(db, filter); err != nil {
+			return err
+		}
+	}
+	write := func(event *database.Event) error {
+		if seen != nil {
+			seen[event.ID] = true
+			lastID = max(lastID, event.ID)
+		}
+		return out.Write(event)
+	}
+
+	// Without a limit, stream everything oldest first in constant memory
+	if queryLimit <= 0 {
+		filter.Ascending = true
+		if err := db.IterateEvents(filter, write); err != nil {
+			return fmt.Errorf("failed to query events: %w", err)
+		}
+		if queryFollow {
+			return follow(db, filter, seen, lastID, out)
+		}
+		return out.Close()
+	}
+
+	// Paging forward reads oldest first; everything else reads the newest
+	// page and reverses it to show oldest first
+	filter.Ascending = filter.After != nil && filter.Before == nil
+
 	// Query events
 	events, err := db.QueryEvents(filter)
 	if err != nil {
 		return fmt.Errorf("failed to query events: %w", err)
 	}
 
-	// Reverse to show oldest first
-	for i := len(events)/2 - 1; i >= 0; i-- {
-		opp := len(events) - 1 - i
-		events[i], events[opp] = events[opp], events[i]
+	if !filter.Ascending {
+		// Reverse to show oldest first
+		slices.Reverse(events)
+	}
+
+	for _, event := range events {
+		if err := write(event); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+
+	if queryFollow {
+		filter.Limit = 0
+		filter.After, filter.Before = nil, nil
+		return follow(db, filter, seen, lastID, out)
 	}
 
-	// Render timeline
-	renderer := timeline.NewRenderer(!queryNoColor)
-	output := renderer.Render(events)
-	fmt.Print(output)
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	// A full page means there may be more events beyond it. Machine-readable
+	// output keeps the hint on stderr.
+	hints := stdout
+	if queryFormat != "text" {
+		hints = os.Stderr
+	}
+	if len(events) == queryLimit {
+		if filter.Ascending {
+			fmt.Fprintf(hints, "Newer events: --after %s\n", database.CursorOf(events[len(events)-1]))
+		} else {
+			fmt.Fprintf(hints, "Older events: --before %s\n", database.CursorOf(events[0]))
+		}
+	}
 
 	return nil
 }
 
-func parseTime(timeStr string) (time.Time, error) {
-	// Try parsing as RFC3339
-	if t, err := time.Parse(time.RFC3339, timeStr); err == nil {
-		return t, nil
+// followWindow is how many ids below the highest one seen follow checks
+// again on each poll, on backends that do not commit in id order.
+// PostgreSQL assigns ids as rows are inserted rather than as they commit, so
+// a concurrent writer can commit an event with a lower id after higher ones
+// were printed.
+const followWindow = 10000
+
+// lookback returns how far below the highest id seen db can still commit
+// events.
+func lookback(db database.Store) int64 {
+	if db.CommitsInIDOrder() {
+		return 0
+	}
+	return followWindow
+}
+
+// followStart returns the ids of the latest events matching filter, up to
+// lookback below the highest id, and that id.
+func followStart(db database.Store, filter database.QueryFilter) (map[int64]bool, int64, error) {
+	lastID, err := db.LatestEventID()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seen := make(map[int64]bool)
+	filter.Limit = 0
+	filter.After, filter.Before = nil, nil
+	filter.AfterID = max(lastID-lookback(db), 0)
+	err = db.IterateEvents(filter, func(event *database.Event) error {
+		seen[event.ID] = true
+		lastID = max(lastID, event.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query events: %w", err)
 	}
 
-	// Try parsing as duration (e.g., "-24h", "-1h30m")
-	if len(timeStr) > 0 && timeStr[0] == '-' {
-		duration, err := time.ParseDuration(timeStr[1:])
-		if err == nil {
-			return time.Now().Add(-duration), nil
+	return seen, lastID, nil
+}
+
+// follow polls for events matching filter that are not in seen, either
+// inserted after lastID or committed late within lookback below it, and
+// writes them until interrupted, then closes out.
+func follow(db database.Store, filter database.QueryFilter, seen map[int64]bool, lastID int64, out export.EventWriter) error {
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(queryPoll)
+	defer ticker.Stop()
+
+	window := lookback(db)
+	filter.Ascending = true
+	for {
+		select {
+		case <-ctx.Done():
+			return out.Close()
+		case <-ticker.C:
+		}
+
+		filter.AfterID = max(lastID-window, 0)
+		written := 0
+		err := db.IterateEvents(filter, func(event *database.Event) error {
+			if seen[event.ID] {
+				return nil
+			}
+			seen[event.ID] = true
+			lastID = max(lastID, event.ID)
+			written++
+			return out.Write(event)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to query events: %w", err)
+		}
+
+		for id := range seen {
+			if id <= lastID-window {
+				delete(seen, id)
+			}
+		}
+
+		if written > 0 {
+			if err := out.Flush(); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
 		}
 	}
+}
+
+// parseTime accepts any expression understood by timespec.Parse, reading
+// times without a zone in the --tz location.
+func parseTime(timeStr string) (time.Time, error) {
+	return timespec.Parse(timeStr, time.Now(), time.Local)
+}
 
-	return time.Time{}, fmt.Errorf("invalid time format (use RFC3339 or relative like -24h)")
+// parseEnd is parseTime for the end of a range, which covers the whole day
+// when timeStr names one.
+func parseEnd(timeStr string) (time.Time, error) {
+	return timespec.ParseEnd(timeStr, time.Now(), time.Local)
 }
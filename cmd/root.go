@@ -1,21 +1,56 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
+var timeZoneName string
+
 var rootCmd = &cobra.Command{
 	Use:   "fstimeline",
 	Short: "A file system timeline monitor",
 	Long:  `Monitor file system changes over time and query historical events.`,
+
+	PersistentPreRunE: setTimeZone,
+
+	// main prints the error once; the usage would bury it
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
 
+// setTimeZone makes --tz the local time zone, used both for reading times
+// given without a zone and for displaying event times.
+func setTimeZone(cmd *cobra.Command, args []string) error {
+	if timeZoneName == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(timeZoneName)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", timeZoneName, err)
+	}
+	time.Local = loc
+
+	return nil
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&timeZoneName, "tz", "", "Time zone for reading and displaying times, e.g. Europe/Berlin or UTC (default: local)")
 	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(queryCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(histogramCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(tuiCmd)
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/timeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchDBPath  string
+	searchLimit   int
+	searchNoColor bool
+	searchFilter  filterOptions
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <terms>...",
+	Short: "Search file paths",
+	Long: `Find files whose path contains every term, ranked by relevance. Terms match
+the start of any word in the path, so "conf" finds "app/config.yaml".`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSearch,
+}
+
+func init() {
+	searchCmd.Flags().StringVarP(&searchDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "l", 20, "Limit number of results")
+	searchCmd.Flags().BoolVarP(&searchNoColor, "no-color", "n", false, "Disable colored output")
+	searchFilter.addFlags(searchCmd.Flags())
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	// Open database
+	db, err := database.OpenReadOnly(searchDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	filter := database.QueryFilter{}
+	if err := searchFilter.apply(&filter, nil); err != nil {
+		return err
+	}
+
+	results, err := db.SearchPaths(args, filter, searchLimit)
+	if err != nil {
+		return err
+	}
+
+	renderer := timeline.NewRenderer(!searchNoColor)
+	fmt.Print(renderer.RenderSearch(results, args))
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsDBPath string
+	sessionsLimit  int
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List watcher sessions",
+	Long:  `List the watcher runs recorded in the database, with the host and path each one watched and the number of events it recorded.`,
+	RunE:  runSessions,
+}
+
+func init() {
+	sessionsCmd.Flags().StringVarP(&sessionsDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	sessionsCmd.Flags().IntVarP(&sessionsLimit, "limit", "l", 20, "Limit number of results")
+}
+
+func runSessions(cmd *cobra.Command, args []string) error {
+	// Open database
+	db, err := database.OpenReadOnly(sessionsDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sessions, err := db.QuerySessions(sessionsLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found.")
+		return nil
+	}
+
+	for _, session := range sessions {
+		ended := "running"
+		if session.EndedAt != nil {
+			ended = session.EndedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %s  %-19s  %8d events  %-20s  %s\n", session.ID,
+			session.StartedAt.Format("2006-01-02 15:04:05"), ended, session.Events, session.Hostname, session.Path)
+	}
+
+	return nil
+}
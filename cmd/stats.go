@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/timeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsDBPath  string
+	statsFilter  filterOptions
+	statsDir     string
+	statsTop     int
+	statsFormat  string
+	statsNoColor bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [expression]",
+	Short: "Summarize file system events",
+	Long: `Report event counts by type and file extension, the busiest directories and
+files, the hours and weekdays with the most activity, and the first and last
+event times. Accepts the same filters and expression language as query.`,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().StringVarP(&statsDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	statsFilter.addFlags(statsCmd.Flags())
+	statsCmd.Flags().StringVarP(&statsDir, "dir", "D", "", "Filter by directory")
+	statsCmd.Flags().IntVar(&statsTop, "top", 10, "Number of file types, directories and files to list")
+	statsCmd.Flags().StringVarP(&statsFormat, "format", "f", "text", "Output format: text or json")
+	statsCmd.Flags().BoolVarP(&statsNoColor, "no-color", "n", false, "Disable colored output")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsFormat != "text" && statsFormat != "json" {
+		return fmt.Errorf("unknown format %q (use text or json)", statsFormat)
+	}
+	if statsTop < 1 {
+		return fmt.Errorf("--top must be at least 1")
+	}
+
+	// Open database
+	db, err := database.OpenReadOnly(statsDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	filter := database.QueryFilter{Directory: statsDir}
+
+	if err := statsFilter.apply(&filter, args); err != nil {
+		return err
+	}
+
+	stats, err := db.Stats(filter, statsTop)
+	if err != nil {
+		return err
+	}
+
+	if statsFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	renderer := timeline.NewRenderer(!statsNoColor)
+	fmt.Print(renderer.RenderStats(stats))
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuiDBPath string
+	tuiFilter filterOptions
+	tuiDir    string
+	tuiLimit  int
+	tuiPoll   time.Duration
+	tuiWindow time.Duration
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [expression]",
+	Short: "Browse the timeline interactively",
+	Long: `Browse events in the terminal: a directory tree with event counts, a
+scrollable event list that follows new events while watch runs, and a detail
+pane with the selected file's history. Press / to edit the filter expression
+(the same language as query) and q to quit.`,
+	RunE: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().StringVarP(&tuiDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
+	tuiFilter.addFlags(tuiCmd.Flags())
+	tuiCmd.Flags().StringVarP(&tuiDir, "dir", "D", "", "Filter by directory")
+	tuiCmd.Flags().IntVarP(&tuiLimit, "limit", "l", 5000, "Number of most recent events to load")
+	tuiCmd.Flags().DurationVar(&tuiPoll, "poll", time.Second, "How often to check for new events")
+	tuiCmd.Flags().DurationVarP(&tuiWindow, "window", "w", time.Second, "Maximum time between a rename and the matching create in a file's history")
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	if tuiPoll <= 0 {
+		return fmt.Errorf("--poll must be positive, got %s", tuiPoll)
+	}
+
+	// Open database
+	db, err := database.OpenReadOnly(tuiDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	filter := database.QueryFilter{Directory: tuiDir}
+
+	// The expression stays editable in the browser, so it is parsed there
+	if err := tuiFilter.apply(&filter, nil); err != nil {
+		return err
+	}
+
+	return tui.Run(db, tui.Options{
+		Filter:     filter,
+		Expression: strings.Join(args, " "),
+		ParseTime:  parseTime,
+		Limit:      tuiLimit,
+		Poll:       tuiPoll,
+		Window:     tuiWindow,
+	})
+}
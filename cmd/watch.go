@@ -3,12 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/export"
 	"github.com/BaseMax/go-fs-timeline/pkg/watcher"
 	"github.com/spf13/cobra"
 )
@@ -18,30 +22,86 @@ var (
 	watchDBPath       string
 	watchFlushSeconds int
 	watchBufferSize   int
+	watchSpoolPath    string
+	watchQueueSize    int
+	watchOverflow     string
+	watchSampleRate   int
+	watchSinks        []string
+	watchSinkHeaders  []string
 )
 
 var watchCmd = &cobra.Command{
 	Use:   "watch",
 	Short: "Watch a directory for file system changes",
-	Long:  `Start monitoring a directory for file system changes and persist events to the database.`,
-	RunE:  runWatch,
+	Long: `Start monitoring a directory for file system changes and persist events to the database.
+With --sink, each flushed batch is also forwarded to a log collector or file.`,
+	RunE: runWatch,
 }
 
 func init() {
 	watchCmd.Flags().StringVarP(&watchPath, "path", "p", ".", "Path to watch")
-	watchCmd.Flags().StringVarP(&watchDBPath, "db", "d", "fstimeline.db", "Database path")
+	watchCmd.Flags().StringVarP(&watchDBPath, "db", "d", "fstimeline.db", "Database path or postgres:// URL")
 	watchCmd.Flags().IntVarP(&watchFlushSeconds, "flush", "f", 5, "Flush interval in seconds")
 	watchCmd.Flags().IntVarP(&watchBufferSize, "buffer", "b", 100, "Maximum buffer size before flush")
+	watchCmd.Flags().IntVarP(&watchQueueSize, "queue", "q", 1000, "Maximum events queued for the flusher")
+	watchCmd.Flags().StringVar(&watchOverflow, "overflow", "block", "Policy when the queue is full (block, drop, sample, coalesce)")
+	watchCmd.Flags().IntVar(&watchSampleRate, "sample-rate", 10, "Keep one in N events while the queue is full (sample policy)")
+	watchCmd.Flags().StringVar(&watchSpoolPath, "spool", "", "Write-ahead spool file for crash-safe buffering; a crash just after a flush commits may record its events twice (disabled if empty)")
+	watchCmd.Flags().StringArrayVar(&watchSinks, "sink", nil, "Also send events as format=url or format=file, e.g. ecs=http://localhost:9200/fstimeline or otlp=http://localhost:4318/v1/logs (repeatable)")
+	watchCmd.Flags().StringArrayVar(&watchSinkHeaders, "sink-header", nil, "HTTP header for sink requests, e.g. 'Authorization: ApiKey ...' (repeatable)")
 }
 
 func runWatch(cmd *cobra.Command, args []string) error {
+	policy, err := watcher.ParseOverflowPolicy(watchOverflow)
+	if err != nil {
+		return err
+	}
+
 	// Open database
-	db, err := database.New(watchDBPath)
+	db, err := database.Open(watchDBPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
+	// Open spool and replay events left over from a previous crash
+	var spool *watcher.Spool
+	if watchSpoolPath != "" {
+		spool, err = watcher.OpenSpool(watchSpoolPath)
+		if err != nil {
+			return fmt.Errorf("failed to open spool: %w", err)
+		}
+		defer spool.Close()
+
+		replayed, err := spool.Replay(db)
+		if err != nil {
+			return fmt.Errorf("failed to replay spool: %w", err)
+		}
+		if replayed > 0 {
+			fmt.Printf("♻️  Recovered %d events from spool\n", replayed)
+		}
+	}
+
+	// Open sinks; they are closed after the watcher's final flush
+	headers := make(http.Header)
+	for _, header := range watchSinkHeaders {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("invalid sink header %q (use 'Name: value')", header)
+		}
+		headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	var sinks []*export.Sink
+	for _, spec := range watchSinks {
+		sink, err := export.ParseSink(spec, headers)
+		if err != nil {
+			return fmt.Errorf("failed to open sink: %w", err)
+		}
+		defer sink.Close()
+		sinks = append(sinks, sink)
+	}
+
 	// Create watcher
 	w, err := watcher.New(db, time.Duration(watchFlushSeconds)*time.Second, watchBufferSize)
 	if err != nil {
@@ -49,6 +109,14 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	}
 	defer w.Close()
 
+	w.SetQueue(watchQueueSize, policy, watchSampleRate)
+	if spool != nil {
+		w.SetSpool(spool)
+	}
+	for _, sink := range sinks {
+		w.AddSink(sink)
+	}
+
 	// Add path to watch
 	if err := w.AddPath(watchPath); err != nil {
 		return fmt.Errorf("failed to add path to watcher: %w", err)
@@ -58,9 +126,30 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	fmt.Printf("💾 Database: %s\n", watchDBPath)
 	fmt.Printf("⏱️  Flush interval: %d seconds\n", watchFlushSeconds)
 	fmt.Printf("📦 Buffer size: %d events\n", watchBufferSize)
+	fmt.Printf("🚦 Queue: %d events (%s when full)\n", watchQueueSize, policy)
+	if spool != nil {
+		fmt.Printf("📝 Spool: %s\n", watchSpoolPath)
+	}
+	for _, sink := range sinks {
+		fmt.Printf("📡 Sink: %s\n", sink)
+	}
 	fmt.Println("Press Ctrl+C to stop...")
 	fmt.Println()
 
+	// Record this run so events can be traced back to the machine
+	session, err := startSession(db, watchPath)
+	if err != nil {
+		return err
+	}
+	w.SetSession(session.ID)
+	defer func() {
+		endedAt := time.Now()
+		session.EndedAt = &endedAt
+		if err := db.EndSession(session); err != nil {
+			fmt.Printf("[ERROR] %v\n", err)
+		}
+	}()
+
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -82,3 +171,26 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	fmt.Println("✅ Shutdown complete")
 	return nil
 }
+
+func startSession(db database.Store, path string) (*database.Session, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	session := &database.Session{
+		Hostname:  hostname,
+		Path:      absPath,
+		StartedAt: time.Now(),
+	}
+	if err := db.StartSession(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
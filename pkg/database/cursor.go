@@ -0,0 +1,50 @@
+package database
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursor is a position in the (timestamp, id) ordering of events. Unlike an
+// offset it stays valid while new events are inserted.
+type Cursor struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+func CursorOf(event *Event) *Cursor {
+	return &Cursor{Timestamp: event.Timestamp, ID: event.ID}
+}
+
+// String encodes the cursor as an opaque token suitable for the command line.
+func (c *Cursor) String() string {
+	raw := c.Timestamp.Format(time.RFC3339Nano) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	timestamp, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor: missing id")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return &Cursor{Timestamp: t, ID: n}, nil
+}
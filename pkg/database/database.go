@@ -3,9 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type Event struct {
@@ -16,60 +15,278 @@ type Event struct {
 	FileName  string
 	FileType  string
 	Directory string
+	// Size is the file size in bytes when the event was seen, or nil if the
+	// file could not be inspected (for example after REMOVE).
+	Size *int64
+	// The file's inode number, mode (st_mode, with the file type bits),
+	// owner and modification, access, change and birth times, like Size
+	// taken when the event was seen. Each is nil where the platform does not
+	// record it.
+	Inode      *int64
+	Mode       *int64
+	UID        *int64
+	GID        *int64
+	ModTime    *time.Time
+	AccessTime *time.Time
+	ChangeTime *time.Time
+	BirthTime  *time.Time
+	// SessionID is the watcher session that recorded the event, or nil for
+	// events recorded before sessions were linked to their events.
+	SessionID *int64
 }
 
-type DB struct {
-	conn *sql.DB
+const (
+	insertEventSQL = `INSERT INTO events (timestamp, event_type, file_path, file_name, file_type, directory, size,
+	                  inode, mode, uid, gid, mtime, atime, ctime, btime, session_id)
+	                  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	eventColumns = `id, timestamp, event_type, file_path, file_name, file_type, directory, size,
+	                inode, mode, uid, gid, mtime, atime, ctime, btime, session_id`
+)
+
+func insertArgs(event *Event) []interface{} {
+	return []interface{}{dbTime(event.Timestamp), event.EventType, event.FilePath,
+		event.FileName, event.FileType, event.Directory, event.Size,
+		event.Inode, event.Mode, event.UID, event.GID,
+		unixNano(event.ModTime), unixNano(event.AccessTime), unixNano(event.ChangeTime), unixNano(event.BirthTime),
+		event.SessionID}
 }
 
-func New(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+// scanEvent reads a row selected with eventColumns.
+func scanEvent(rows *sql.Rows) (*Event, error) {
+	event := &Event{}
+	var size, inode, mode, uid, gid, mtime, atime, ctime, btime, sessionID sql.NullInt64
+	err := rows.Scan(&event.ID, &event.Timestamp, &event.EventType, &event.FilePath,
+		&event.FileName, &event.FileType, &event.Directory, &size,
+		&inode, &mode, &uid, &gid, &mtime, &atime, &ctime, &btime, &sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to scan event: %w", err)
+	}
+	event.Size = nullInt(size)
+	event.Inode = nullInt(inode)
+	event.Mode = nullInt(mode)
+	event.UID = nullInt(uid)
+	event.GID = nullInt(gid)
+	event.ModTime = nullTime(mtime)
+	event.AccessTime = nullTime(atime)
+	event.ChangeTime = nullTime(ctime)
+	event.BirthTime = nullTime(btime)
+	event.SessionID = nullInt(sessionID)
+	event.Timestamp = event.Timestamp.Local()
+	return event, nil
+}
+
+// File times are stored as nanoseconds since the Unix epoch, which every
+// backend holds exactly.
+func unixNano(t *time.Time) *int64 {
+	if t == nil {
+		return nil
 	}
+	nanos := t.UnixNano()
+	return &nanos
+}
 
-	if err := conn.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+func nullInt(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
 	}
+	return &value.Int64
+}
 
-	db := &DB{conn: conn}
-	if err := db.createSchema(); err != nil {
-		return nil, err
+func nullTime(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
 	}
+	t := time.Unix(0, value.Int64)
+	return &t
+}
+
+// Store is the persistence layer used by the watcher and the commands.
+type Store interface {
+	InsertEvents(events []*Event) error
+	QueryEvents(filter QueryFilter) ([]*Event, error)
+	IterateEvents(filter QueryFilter, fn func(*Event) error) error
+	LatestEventID() (int64, error)
+	CommitsInIDOrder() bool
+	InsertOverflowStats(stats *OverflowStats) error
+	Stats(filter QueryFilter, top int) (*Stats, error)
+	Histogram(filter QueryFilter, options HistogramOptions) (*Histogram, error)
+	Prune(before time.Time) (int64, error)
+	StartSession(session *Session) error
+	EndSession(session *Session) error
+	QuerySessions(limit int) ([]*Session, error)
+	SearchPaths(terms []string, filter QueryFilter, limit int) ([]*SearchResult, error)
+	Close() error
+}
+
+// Open opens the store named by dsn for writing. A postgres:// or
+// postgresql:// URL or a libpq key=value DSN selects PostgreSQL; anything
+// else is a SQLite file path.
+func Open(dsn string) (Store, error) {
+	if isPostgresDSN(dsn) {
+		return NewPostgres(dsn)
+	}
+	return New(dsn)
+}
+
+// OpenReadOnly is like Open but for commands that only query.
+func OpenReadOnly(dsn string) (Store, error) {
+	if isPostgresDSN(dsn) {
+		return NewPostgresReadOnly(dsn)
+	}
+	return NewReadOnly(dsn)
+}
+
+// DB is a Store backed by database/sql. The dialect holds what differs
+// between SQLite and PostgreSQL.
+type DB struct {
+	conn    *sql.DB
+	dialect dialect
+	// fts is set when the full-text search index is available.
+	fts bool
+}
 
-	return db, nil
+type dialect struct {
+	name        string
+	schema      string
+	placeholder func(n int) string
+	// nativeGlob is set when the backend has a GLOB operator; otherwise
+	// globs are translated into regular expressions.
+	nativeGlob bool
+	regexOp    string
+	// columnExistsSQL counts the columns named by its (table, column) args.
+	columnExistsSQL string
+	// minuteBucket and hourBucket format an event's UTC minute and hour as
+	// "2006-01-02 15:04" and "2006-01-02 15".
+	minuteBucket string
+	hourBucket   string
+	// addColumnSQL adds the column named by its (table, column, definition)
+	// verbs.
+	addColumnSQL string
+	// lateCommits is set when ids are assigned as rows are inserted rather
+	// than as they commit, so a lower id can become visible after a higher one.
+	lateCommits bool
 }
 
 func (db *DB) createSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS events (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		timestamp DATETIME NOT NULL,
-		event_type TEXT NOT NULL,
-		file_path TEXT NOT NULL,
-		file_name TEXT NOT NULL,
-		file_type TEXT NOT NULL,
-		directory TEXT NOT NULL
-	);
-	CREATE INDEX IF NOT EXISTS idx_timestamp ON events(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_directory ON events(directory);
-	CREATE INDEX IF NOT EXISTS idx_file_type ON events(file_type);
-	`
-
-	_, err := db.conn.Exec(schema)
+	_, err := db.conn.Exec(db.dialect.schema)
 	if err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	for _, column := range addedColumns {
+		exists, err := db.hasColumn(column.table, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf(db.dialect.addColumnSQL, column.table, column.name, column.definition)
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", column.table, column.name, err)
+		}
+	}
+
+	for _, index := range addedIndexes {
+		if _, err := db.conn.Exec(index); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	if db.dialect.name == "sqlite" {
+		return db.migrateToUTC()
+	}
+
 	return nil
 }
 
-func (db *DB) InsertEvent(event *Event) error {
-	query := `INSERT INTO events (timestamp, event_type, file_path, file_name, file_type, directory)
-	          VALUES (?, ?, ?, ?, ?, ?)`
+// addedColumns lists columns introduced after the first release. Databases
+// created by older versions gain them when opened for writing.
+var addedColumns = []struct {
+	table, name, definition string
+}{
+	{"events", "size", "BIGINT"},
+	{"events", "inode", "BIGINT"},
+	{"events", "mode", "BIGINT"},
+	{"events", "uid", "BIGINT"},
+	{"events", "gid", "BIGINT"},
+	{"events", "mtime", "BIGINT"},
+	{"events", "atime", "BIGINT"},
+	{"events", "ctime", "BIGINT"},
+	{"events", "btime", "BIGINT"},
+	{"events", "session_id", "BIGINT REFERENCES sessions(id)"},
+}
+
+// addedIndexes index addedColumns, once they exist.
+var addedIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_session_id ON events(session_id)`,
+}
+
+// schemaOutdated reports whether the database predates one of addedColumns,
+// or for SQLite, the conversion of its timestamps to UTC.
+func (db *DB) schemaOutdated() (bool, error) {
+	for _, column := range addedColumns {
+		exists, err := db.hasColumn(column.table, column.name)
+		if err != nil || !exists {
+			return !exists, err
+		}
+	}
+
+	if db.dialect.name == "sqlite" {
+		version, err := db.sqliteVersion()
+		return version < sqliteUTCVersion, err
+	}
+
+	return false, nil
+}
+
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	var count int
+	if err := db.conn.QueryRow(db.rebind(db.dialect.columnExistsSQL), table, column).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return count > 0, nil
+}
+
+// upgradeReadOnly brings an outdated database up to date through a short-lived
+// writable connection, so read-only commands work on databases written by
+// older versions.
+func upgradeReadOnly(db *DB, openWritable func() (*DB, error)) error {
+	outdated, err := db.schemaOutdated()
+	if err != nil || !outdated {
+		return err
+	}
+
+	writable, err := openWritable()
+	if err != nil {
+		return fmt.Errorf("database needs a schema upgrade: %w", err)
+	}
+	return writable.Close()
+}
+
+// rebind rewrites the ? placeholders used throughout this package into the
+// backend's native form.
+func (db *DB) rebind(query string) string {
+	if db.dialect.placeholder == nil {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString(db.dialect.placeholder(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
 
-	_, err := db.conn.Exec(query, event.Timestamp, event.EventType, event.FilePath,
-		event.FileName, event.FileType, event.Directory)
+func (db *DB) InsertEvent(event *Event) error {
+	err := db.conn.QueryRow(db.rebind(insertEventSQL), insertArgs(event)...).Scan(&event.ID)
 	if err != nil {
 		return fmt.Errorf("failed to insert event: %w", err)
 	}
@@ -77,6 +294,8 @@ func (db *DB) InsertEvent(event *Event) error {
 	return nil
 }
 
+// InsertEvents writes events in one transaction and sets their IDs once it
+// has committed.
 func (db *DB) InsertEvents(events []*Event) error {
 	tx, err := db.conn.Begin()
 	if err != nil {
@@ -84,90 +303,281 @@ func (db *DB) InsertEvents(events []*Event) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT INTO events (timestamp, event_type, file_path, file_name, file_type, directory)
-	                         VALUES (?, ?, ?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(db.rebind(insertEventSQL))
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	for _, event := range events {
-		_, err := stmt.Exec(event.Timestamp, event.EventType, event.FilePath,
-			event.FileName, event.FileType, event.Directory)
-		if err != nil {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		if err := stmt.QueryRow(insertArgs(event)...).Scan(&ids[i]); err != nil {
 			return fmt.Errorf("failed to insert event: %w", err)
 		}
 	}
 
+	if db.fts {
+		if _, err := tx.Exec(indexFTSSQL); err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	for i, event := range events {
+		event.ID = ids[i]
+	}
+
 	return nil
 }
 
-type QueryFilter struct {
-	StartTime *time.Time
-	EndTime   *time.Time
-	FileType  string
-	Directory string
-	Limit     int
+// OverflowStats records events the watcher lost or merged because its queue
+// was full, or because the kernel event queue overflowed.
+type OverflowStats struct {
+	ID              int64
+	Timestamp       time.Time
+	Policy          string
+	Dropped         int64
+	Coalesced       int64
+	KernelOverflows int64
 }
 
-func (db *DB) QueryEvents(filter QueryFilter) ([]*Event, error) {
-	query := `SELECT id, timestamp, event_type, file_path, file_name, file_type, directory
-	          FROM events WHERE 1=1`
-	args := []interface{}{}
+func (db *DB) InsertOverflowStats(stats *OverflowStats) error {
+	query := `INSERT INTO overflow_stats (timestamp, policy, dropped, coalesced, kernel_overflows)
+	          VALUES (?, ?, ?, ?, ?)`
 
-	if filter.StartTime != nil {
-		query += " AND timestamp >= ?"
-		args = append(args, filter.StartTime)
+	_, err := db.conn.Exec(db.rebind(query), dbTime(stats.Timestamp), stats.Policy, stats.Dropped,
+		stats.Coalesced, stats.KernelOverflows)
+	if err != nil {
+		return fmt.Errorf("failed to insert overflow stats: %w", err)
 	}
 
-	if filter.EndTime != nil {
-		query += " AND timestamp <= ?"
-		args = append(args, filter.EndTime)
-	}
+	return nil
+}
 
-	if filter.FileType != "" {
-		query += " AND file_type = ?"
-		args = append(args, filter.FileType)
+func (db *DB) QueryEvents(filter QueryFilter) ([]*Event, error) {
+	var events []*Event
+	err := db.IterateEvents(filter, func(event *Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if filter.Directory != "" {
-		query += " AND directory LIKE ?"
-		args = append(args, filter.Directory+"%")
-	}
+	return events, nil
+}
 
-	query += " ORDER BY timestamp DESC"
+// IterateEvents calls fn for each matching event as rows are read, so callers
+// can process any number of events in constant memory. Iteration stops at
+// the first error returned by fn.
+func (db *DB) IterateEvents(filter QueryFilter, fn func(*Event) error) error {
+	where, args := db.where(filter)
+	query := `SELECT ` + eventColumns + ` FROM events WHERE ` + where
+
+	if filter.Ascending {
+		query += " ORDER BY timestamp ASC, id ASC"
+	} else {
+		query += " ORDER BY timestamp DESC, id DESC"
+	}
 
 	if filter.Limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, filter.Limit)
 	}
 
-	rows, err := db.conn.Query(query, args...)
+	rows, err := db.conn.Query(db.rebind(query), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+		return fmt.Errorf("failed to query events: %w", err)
 	}
 	defer rows.Close()
 
-	var events []*Event
 	for rows.Next() {
-		event := &Event{}
-		err := rows.Scan(&event.ID, &event.Timestamp, &event.EventType, &event.FilePath,
-			&event.FileName, &event.FileType, &event.Directory)
+		event, err := scanEvent(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan event: %w", err)
+			return err
 		}
-		events = append(events, event)
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// LatestEventID returns the id of the most recently inserted event, or 0 if
+// there are none.
+func (db *DB) LatestEventID() (int64, error) {
+	var id sql.NullInt64
+	if err := db.conn.QueryRow(`SELECT MAX(id) FROM events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to query latest event: %w", err)
+	}
+	return id.Int64, nil
+}
+
+// CommitsInIDOrder reports whether events become visible in id order, so
+// that readers following new events need only look past the last id seen.
+func (db *DB) CommitsInIDOrder() bool {
+	return !db.dialect.lateCommits
+}
+
+// Prune deletes events older than before and returns how many were removed.
+func (db *DB) Prune(before time.Time) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if db.fts {
+		_, err := tx.Exec(`DELETE FROM events_fts WHERE rowid IN (SELECT id FROM events WHERE timestamp < ?)`, dbTime(before))
+		if err != nil {
+			return 0, fmt.Errorf("failed to prune search index: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(db.rebind(`DELETE FROM events WHERE timestamp < ?`), dbTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned events: %w", err)
+	}
+
+	return count, nil
+}
+
+// Session records one run of the watcher, so events in a shared database can
+// be traced back to the machine and path that produced them through their
+// SessionID.
+type Session struct {
+	ID        int64
+	Hostname  string
+	Path      string
+	StartedAt time.Time
+	EndedAt   *time.Time
+	// Events counts the events recorded in the session; QuerySessions fills
+	// it in.
+	Events int64
+}
+
+func (db *DB) StartSession(session *Session) error {
+	query := `INSERT INTO sessions (hostname, path, started_at) VALUES (?, ?, ?) RETURNING id`
+
+	err := db.conn.QueryRow(db.rebind(query), session.Hostname, session.Path,
+		dbTime(session.StartedAt)).Scan(&session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) EndSession(session *Session) error {
+	var endedAt interface{}
+	if session.EndedAt != nil {
+		endedAt = dbTime(*session.EndedAt)
+	}
+
+	_, err := db.conn.Exec(db.rebind(`UPDATE sessions SET ended_at = ? WHERE id = ?`),
+		endedAt, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) QuerySessions(limit int) ([]*Session, error) {
+	query := `SELECT id, hostname, path, started_at, ended_at,
+	          (SELECT COUNT(*) FROM events WHERE events.session_id = sessions.id)
+	          FROM sessions ORDER BY started_at DESC`
+	args := []interface{}{}
+
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.Query(db.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session := &Session{}
+		var endedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &session.Hostname, &session.Path,
+			&session.StartedAt, &endedAt, &session.Events); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.StartedAt = session.StartedAt.Local()
+		if endedAt.Valid {
+			ended := endedAt.Time.Local()
+			session.EndedAt = &ended
+		}
+		sessions = append(sessions, session)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	return events, nil
+	return sessions, nil
+}
+
+// sqliteTimeFormats are the layouts the SQLite drivers write timestamps in.
+var sqliteTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseDBTime converts an aggregated timestamp such as MAX(timestamp). SQLite
+// drivers only return time.Time for columns declared as DATETIME, so
+// aggregates come back as text.
+func parseDBTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Local(), nil
+	case []byte:
+		return parseDBTime(string(v))
+	case string:
+		for _, format := range sqliteTimeFormats {
+			if t, err := time.Parse(format, strings.TrimSuffix(v, "Z")); err == nil {
+				return t.Local(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized timestamp %q", v)
+	case nil:
+		return time.Time{}, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unexpected timestamp type %T", value)
+}
+
+// dbTime normalizes t to UTC before it is stored or compared. SQLite keeps
+// timestamps as text, so values written with different offsets would not
+// sort in time order.
+func dbTime(t time.Time) time.Time {
+	return t.UTC()
 }
 
 func (db *DB) Close() error {
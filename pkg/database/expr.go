@@ -0,0 +1,97 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/query"
+)
+
+var exprColumns = map[string]string{
+	"type":  "file_type",
+	"event": "event_type",
+	"path":  "file_path",
+	"name":  "file_name",
+	"dir":   "directory",
+	"time":  "timestamp",
+	"size":  "size",
+}
+
+// compileExpr translates a parsed query expression into a parameterized SQL
+// condition. The parser has already validated fields, operators and values.
+func (db *DB) compileExpr(node query.Node, args *[]interface{}) string {
+	switch n := node.(type) {
+	case *query.And:
+		return "(" + db.compileExpr(n.Left, args) + " AND " + db.compileExpr(n.Right, args) + ")"
+
+	case *query.Or:
+		return "(" + db.compileExpr(n.Left, args) + " OR " + db.compileExpr(n.Right, args) + ")"
+
+	case *query.Not:
+		return "NOT " + db.compileExpr(n.Expr, args)
+
+	case *query.Comparison:
+		return db.compileComparison(n, args)
+	}
+
+	return "1=1"
+}
+
+func (db *DB) compileComparison(n *query.Comparison, args *[]interface{}) string {
+	column := exprColumns[n.Field]
+
+	var value interface{} = n.Value
+	switch n.Field {
+	case "time":
+		value = dbTime(n.Time)
+	case "size":
+		value = n.Size
+	}
+
+	switch n.Op {
+	case query.OpRegex:
+		// Event types are stored upper case but written in any case
+		pattern := n.Value
+		if n.Field == "event" {
+			pattern = "(?i)" + pattern
+		}
+		*args = append(*args, pattern)
+		return column + " " + db.dialect.regexOp + " ?"
+
+	case query.OpMatch:
+		return db.compileMatch(column, n.Field, n.Value, args)
+
+	case query.OpNe:
+		*args = append(*args, value)
+		return column + " <> ?"
+
+	default:
+		*args = append(*args, value)
+		return column + " " + string(n.Op) + " ?"
+	}
+}
+
+// compileMatch gives ":" its loose meaning: a glob or substring for paths and
+// names, a prefix for directories, and equality for everything else.
+func (db *DB) compileMatch(column, field, value string, args *[]interface{}) string {
+	switch field {
+	case "path", "name":
+		if strings.ContainsAny(value, "*?[") {
+			if db.dialect.nativeGlob {
+				*args = append(*args, value)
+				return column + " GLOB ?"
+			}
+			*args = append(*args, globToRegex(value))
+			return column + " " + db.dialect.regexOp + " ?"
+		}
+		*args = append(*args, "%"+escapeLike(value)+"%")
+		return column + " LIKE ? ESCAPE '\\'"
+
+	case "dir":
+		*args = append(*args, escapeLike(value)+"%")
+		return column + " LIKE ? ESCAPE '\\'"
+
+	default:
+		*args = append(*args, value)
+		return column + " = ?"
+	}
+}
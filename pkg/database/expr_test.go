@@ -0,0 +1,131 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/query"
+)
+
+// parseTestTime accepts RFC3339 and "-<duration>" offsets from the end of
+// the test events.
+func parseTestTime(s string) (time.Time, error) {
+	if offset, ok := strings.CutPrefix(s, "-"); ok {
+		d, err := time.ParseDuration(offset)
+		return testStart.Add(2 * time.Hour).Add(-d), err
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func TestCompileExpr(t *testing.T) {
+	tests := []struct {
+		expr     string
+		sqlite   string
+		postgres string
+		args     []interface{}
+	}{
+		{`type:go`, `file_type = ?`, `file_type = $1`, []interface{}{"go"}},
+		{`event:write`, `event_type = ?`, `event_type = $1`, []interface{}{"WRITE"}},
+		{`path:app/main`, `file_path LIKE ? ESCAPE '\'`, `file_path LIKE $1 ESCAPE '\'`, []interface{}{"%app/main%"}},
+		{`name:100%_done`, `file_name LIKE ? ESCAPE '\'`, `file_name LIKE $1 ESCAPE '\'`, []interface{}{`%100\%\_done%`}},
+		{`path:*/app/*.go`, `file_path GLOB ?`, `file_path ~ $1`, nil},
+		{`dir:/src`, `directory LIKE ? ESCAPE '\'`, `directory LIKE $1 ESCAPE '\'`, []interface{}{"/src%"}},
+		{`path:~\.ya?ml$`, `file_path REGEXP ?`, `file_path ~ $1`, []interface{}{`\.ya?ml$`}},
+		{`event:~^\w+$`, `event_type REGEXP ?`, `event_type ~ $1`, []interface{}{`(?i)^\w+$`}},
+		{`type != md`, `file_type <> ?`, `file_type <> $1`, []interface{}{"md"}},
+		{`size >= 1KB`, `size >= ?`, `size >= $1`, []interface{}{int64(1024)}},
+		{`time < 2026-03-14T11:00:00+02:00`, `timestamp < ?`, `timestamp < $1`,
+			[]interface{}{time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)}},
+		{`type:go AND NOT (event:remove OR size > 0)`,
+			`(file_type = ? AND NOT (event_type = ? OR size > ?))`,
+			`(file_type = $1 AND NOT (event_type = $2 OR size > $3))`,
+			[]interface{}{"go", "REMOVE", int64(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			q, err := query.Parse(tt.expr, parseTestTime)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			for _, d := range []dialect{sqliteDialect, postgresDialect} {
+				db := &DB{dialect: d}
+				var args []interface{}
+				got := db.rebind(db.compileExpr(q.Root, &args))
+
+				want := tt.sqlite
+				if d.name == "postgres" {
+					want = tt.postgres
+				}
+				if got != want {
+					t.Errorf("%s: %s, want %s", d.name, got, want)
+				}
+				if tt.args != nil && !reflect.DeepEqual(args, tt.args) {
+					t.Errorf("%s: args %#v, want %#v", d.name, args, tt.args)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	q, err := query.Parse(`path:"/src/[a-z]*/main.go?"`, parseTestTime)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"sqlite":   "/src/[a-z]*/main.go?",
+		"postgres": `^/src/[a-z].*/main\.go.$`,
+	}
+	for _, d := range []dialect{sqliteDialect, postgresDialect} {
+		var args []interface{}
+		(&DB{dialect: d}).compileExpr(q.Root, &args)
+		if len(args) != 1 || args[0] != want[d.name] {
+			t.Errorf("%s: args %v, want %v", d.name, args, want[d.name])
+		}
+	}
+}
+
+func TestExpressionResults(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{`type:go`, 3},
+		{`type:go event:write`, 2},
+		{`type:go OR type:yaml`, 5},
+		{`NOT dir:/src`, 1},
+		{`path:app`, 4},
+		{`path:/src/*/*.go`, 3},
+		{`name:~"^conf"`, 2},
+		{`size > 1KB`, 1},
+		{`size < 1KB`, 0},
+		{`time > -90m`, 2},
+		{`time >= 2026-03-14T09:02:00Z AND time <= 2026-03-14T10:00:00Z`, 3},
+		{`event:create AND NOT (type:yaml OR dir:/tmp)`, 1},
+		{`event:~^\w+$`, 6},
+		{`event:~"^(create|remove)$"`, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			q, err := query.Parse(tt.expr, parseTestTime)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			events, err := db.QueryEvents(QueryFilter{Expression: q})
+			if err != nil {
+				t.Fatalf("QueryEvents: %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("got %d events, want %d:\n%s", len(events), tt.want, strings.Join(paths(events), "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/query"
+)
+
+type QueryFilter struct {
+	StartTime *time.Time
+	EndTime   *time.Time
+	FileTypes []string
+	Directory string
+	Limit     int
+
+	// EventTypes keeps only these event types; ExcludeEventTypes drops them.
+	EventTypes        []string
+	ExcludeEventTypes []string
+	// FilePath keeps only events for exactly this path.
+	FilePath string
+	// PathGlob and PathRegex match against the full file path.
+	PathGlob  string
+	PathRegex string
+	// NameContains matches a substring of the file name.
+	NameContains       string
+	ExcludeDirectories []string
+	// Expression is a parsed query language expression combined with the
+	// other fields.
+	Expression *query.Query
+
+	// Ascending returns the oldest events first instead of the newest.
+	Ascending bool
+	// After and Before restrict results to events strictly after or before a
+	// position in (timestamp, id) order, for keyset pagination.
+	After  *Cursor
+	Before *Cursor
+	// AfterID keeps only events inserted after the event with this id, for
+	// following new events as they are written.
+	AfterID int64
+	// UpToID keeps only events up to and including the event with this id,
+	// so that several queries see the same events while new ones are written.
+	UpToID int64
+}
+
+// Validate reports filter values that would fail inside the database with a
+// less helpful message.
+func (filter QueryFilter) Validate() error {
+	if filter.PathRegex != "" {
+		if _, err := regexp.Compile(filter.PathRegex); err != nil {
+			return fmt.Errorf("invalid path regex: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// where returns the SQL condition and arguments selecting the events that
+// match the filter. Limit is not part of the condition.
+func (db *DB) where(filter QueryFilter) (string, []interface{}) {
+	query := "1=1"
+	args := []interface{}{}
+
+	if filter.StartTime != nil {
+		query += " AND timestamp >= ?"
+		args = append(args, dbTime(*filter.StartTime))
+	}
+
+	if filter.EndTime != nil {
+		query += " AND timestamp <= ?"
+		args = append(args, dbTime(*filter.EndTime))
+	}
+
+	if len(filter.FileTypes) > 0 {
+		query += " AND file_type IN (" + placeholders(len(filter.FileTypes)) + ")"
+		for _, fileType := range filter.FileTypes {
+			args = append(args, fileType)
+		}
+	}
+
+	if filter.FilePath != "" {
+		query += " AND file_path = ?"
+		args = append(args, filter.FilePath)
+	}
+
+	if filter.Directory != "" {
+		query += " AND directory LIKE ? ESCAPE '\\'"
+		args = append(args, escapeLike(filter.Directory)+"%")
+	}
+
+	for _, directory := range filter.ExcludeDirectories {
+		query += " AND directory NOT LIKE ? ESCAPE '\\'"
+		args = append(args, escapeLike(directory)+"%")
+	}
+
+	if len(filter.EventTypes) > 0 {
+		query += " AND event_type IN (" + placeholders(len(filter.EventTypes)) + ")"
+		for _, eventType := range filter.EventTypes {
+			args = append(args, eventType)
+		}
+	}
+
+	if len(filter.ExcludeEventTypes) > 0 {
+		query += " AND event_type NOT IN (" + placeholders(len(filter.ExcludeEventTypes)) + ")"
+		for _, eventType := range filter.ExcludeEventTypes {
+			args = append(args, eventType)
+		}
+	}
+
+	if filter.PathGlob != "" {
+		if db.dialect.nativeGlob {
+			query += " AND file_path GLOB ?"
+			args = append(args, filter.PathGlob)
+		} else {
+			query += " AND file_path " + db.dialect.regexOp + " ?"
+			args = append(args, globToRegex(filter.PathGlob))
+		}
+	}
+
+	if filter.PathRegex != "" {
+		query += " AND file_path " + db.dialect.regexOp + " ?"
+		args = append(args, filter.PathRegex)
+	}
+
+	if filter.NameContains != "" {
+		query += " AND file_name LIKE ? ESCAPE '\\'"
+		args = append(args, "%"+escapeLike(filter.NameContains)+"%")
+	}
+
+	if filter.Expression != nil {
+		query += " AND " + db.compileExpr(filter.Expression.Root, &args)
+	}
+
+	if filter.After != nil {
+		query += " AND (timestamp > ? OR (timestamp = ? AND id > ?))"
+		after := dbTime(filter.After.Timestamp)
+		args = append(args, after, after, filter.After.ID)
+	}
+
+	if filter.AfterID > 0 {
+		query += " AND id > ?"
+		args = append(args, filter.AfterID)
+	}
+
+	if filter.UpToID > 0 {
+		query += " AND id <= ?"
+		args = append(args, filter.UpToID)
+	}
+
+	if filter.Before != nil {
+		query += " AND (timestamp < ? OR (timestamp = ? AND id < ?))"
+		before := dbTime(filter.Before.Timestamp)
+		args = append(args, before, before, filter.Before.ID)
+	}
+
+	return query, args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// globToRegex translates a shell glob into an anchored regular expression
+// with the same meaning as SQLite's GLOB operator.
+func globToRegex(glob string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+
+	inClass := false
+	for _, r := range glob {
+		switch {
+		case inClass:
+			if r == ']' {
+				inClass = false
+			}
+			builder.WriteRune(r)
+		case r == '*':
+			builder.WriteString(".*")
+		case r == '?':
+			builder.WriteString(".")
+		case r == '[':
+			inClass = true
+			builder.WriteRune(r)
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	builder.WriteString("$")
+	return builder.String()
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interval is the width of a histogram bucket.
+type Interval string
+
+const (
+	IntervalMinute Interval = "minute"
+	IntervalHour   Interval = "hour"
+	IntervalDay    Interval = "day"
+	IntervalWeek   Interval = "week"
+)
+
+// ParseInterval converts a command line value to an Interval.
+func ParseInterval(s string) (Interval, error) {
+	switch interval := Interval(strings.ToLower(s)); interval {
+	case IntervalMinute, IntervalHour, IntervalDay, IntervalWeek:
+		return interval, nil
+	}
+	return "", fmt.Errorf("unknown interval %q (use minute, hour, day or week)", s)
+}
+
+// IntervalFor picks an interval that splits span into a readable number of
+// buckets.
+func IntervalFor(span time.Duration) Interval {
+	switch {
+	case span <= 3*time.Hour:
+		return IntervalMinute
+	case span <= 7*24*time.Hour:
+		return IntervalHour
+	case span <= 180*24*time.Hour:
+		return IntervalDay
+	}
+	return IntervalWeek
+}
+
+// Start returns the start of the bucket containing t. Days and weeks begin
+// at local midnight, weeks on Monday.
+func (i Interval) Start(t time.Time) time.Time {
+	t = t.Local()
+	switch i {
+	case IntervalMinute:
+		return t.Truncate(time.Minute)
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		return day.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Next returns the start of the bucket after the one starting at start.
+func (i Interval) Next(start time.Time) time.Time {
+	switch i {
+	case IntervalMinute:
+		return start.Add(time.Minute)
+	case IntervalHour:
+		return start.Add(time.Hour)
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// HistogramOptions controls how Histogram buckets events. GroupBy is empty,
+// "type" or "dir"; with Top set, only the Top largest groups are kept and
+// the rest are merged into "other".
+type HistogramOptions struct {
+	Interval Interval
+	GroupBy  string
+	Top      int
+}
+
+// Histogram counts events per bucket, one series per group. Buckets are
+// contiguous from the first event to the last, so empty buckets count zero.
+type Histogram struct {
+	Interval Interval    `json:"interval"`
+	Buckets  []time.Time `json:"buckets"`
+	Series   []Series    `json:"series"`
+}
+
+// Series holds a group's count for each bucket of a Histogram.
+type Series struct {
+	Name   string  `json:"name"`
+	Total  int64   `json:"total"`
+	Counts []int64 `json:"counts"`
+}
+
+// maxBuckets keeps a histogram over a long span at a fine interval from
+// exhausting memory.
+const maxBuckets = 100000
+
+var histogramGroups = map[string]string{
+	"type": "event_type",
+	"dir":  "directory",
+}
+
+// Histogram buckets the events matching filter by time. The database counts
+// events per UTC minute or hour, which are then gathered into buckets in the
+// local time zone. UTC hours only fall within local hours when the zone is a
+// whole number of hours from UTC, so other zones are counted by minute.
+func (db *DB) Histogram(filter QueryFilter, options HistogramOptions) (*Histogram, error) {
+	group := ""
+	if options.GroupBy != "" {
+		column, ok := histogramGroups[options.GroupBy]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %q (use type or dir)", options.GroupBy)
+		}
+		group = column
+	}
+
+	minutes := options.Interval == IntervalMinute
+	rows, err := db.bucketCounts(filter, group, minutes)
+	if err != nil {
+		return nil, err
+	}
+	if !minutes && len(rows) > 0 && !hourAligned(rows[0].start, rows[len(rows)-1].start.Add(time.Hour)) {
+		if rows, err = db.bucketCounts(filter, group, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Buckets are keyed by Unix time, since equal times need not be == as keys
+	counts := make(map[string]map[int64]int64)
+	totals := make(map[string]int64)
+	var first, last time.Time
+	for _, row := range rows {
+		start := options.Interval.Start(row.start)
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+
+		if counts[row.name] == nil {
+			counts[row.name] = make(map[int64]int64)
+		}
+		counts[row.name][start.Unix()] += row.count
+		totals[row.name] += row.count
+	}
+
+	histogram := &Histogram{Interval: options.Interval}
+	if len(counts) == 0 {
+		return histogram, nil
+	}
+
+	index := make(map[int64]int)
+	for start := first; !start.After(last); start = options.Interval.Next(start) {
+		if len(histogram.Buckets) == maxBuckets {
+			return nil, fmt.Errorf("too many %s buckets, use a longer interval or a shorter time range", options.Interval)
+		}
+		index[start.Unix()] = len(histogram.Buckets)
+		histogram.Buckets = append(histogram.Buckets, start)
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] != totals[names[j]] {
+			return totals[names[i]] > totals[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	for i, name := range names {
+		if options.Top > 0 && i == options.Top {
+			other := Series{Name: "other", Counts: make([]int64, len(histogram.Buckets))}
+			for _, name := range names[i:] {
+				for start, count := range counts[name] {
+					other.Counts[index[start]] += count
+				}
+				other.Total += totals[name]
+			}
+			histogram.Series = append(histogram.Series, other)
+			break
+		}
+
+		series := Series{Name: name, Total: totals[name], Counts: make([]int64, len(histogram.Buckets))}
+		if options.GroupBy == "" {
+			series.Name = "all"
+		}
+		for start, count := range counts[name] {
+			series.Counts[index[start]] = count
+		}
+		histogram.Series = append(histogram.Series, series)
+	}
+
+	return histogram, nil
+}
+
+// bucketCount is the number of events of a group in a UTC minute or hour.
+type bucketCount struct {
+	start time.Time
+	name  string
+	count int64
+}
+
+// bucketCounts counts the events matching filter per UTC hour, or minute
+// with minutes set, and value of the group column if any, oldest first.
+func (db *DB) bucketCounts(filter QueryFilter, group string, minutes bool) ([]bucketCount, error) {
+	bucket, layout := db.dialect.hourBucket, "2006-01-02 15"
+	if minutes {
+		bucket, layout = db.dialect.minuteBucket, "2006-01-02 15:04"
+	}
+
+	where, args := db.where(filter)
+	query := `SELECT ` + bucket + `, '', COUNT(*) FROM events WHERE ` + where +
+		` GROUP BY ` + bucket + ` ORDER BY ` + bucket
+	if group != "" {
+		query = `SELECT ` + bucket + `, ` + group + `, COUNT(*) FROM events WHERE ` + where +
+			` GROUP BY ` + bucket + `, ` + group + ` ORDER BY ` + bucket
+	}
+
+	rows, err := db.conn.Query(db.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query histogram: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []bucketCount
+	for rows.Next() {
+		var key string
+		var count bucketCount
+		if err := rows.Scan(&key, &count.name, &count.count); err != nil {
+			return nil, fmt.Errorf("failed to scan histogram: %w", err)
+		}
+		if count.start, err = time.Parse(layout, strings.Replace(key, "T", " ", 1)); err != nil {
+			return nil, fmt.Errorf("unrecognized bucket %q: %w", key, err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// hourAligned reports whether the local time zone is a whole number of hours
+// from UTC throughout from to to, so that every UTC hour falls within one
+// local hour.
+func hourAligned(from, to time.Time) bool {
+	for t := from.Local(); ; {
+		if _, offset := t.Zone(); offset%3600 != 0 {
+			return false
+		}
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			return true
+		}
+		t = end
+	}
+}
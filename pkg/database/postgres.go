@@ -0,0 +1,158 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+const postgresSchema = `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id BIGSERIAL PRIMARY KEY,
+		hostname TEXT NOT NULL,
+		path TEXT NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ
+	);
+	CREATE TABLE IF NOT EXISTS events (
+		id BIGSERIAL PRIMARY KEY,
+		timestamp TIMESTAMPTZ NOT NULL,
+		event_type TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		file_type TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		size BIGINT,
+		inode BIGINT,
+		mode BIGINT,
+		uid BIGINT,
+		gid BIGINT,
+		mtime BIGINT,
+		atime BIGINT,
+		ctime BIGINT,
+		btime BIGINT,
+		session_id BIGINT REFERENCES sessions(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_timestamp ON events(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_directory ON events(directory);
+	CREATE INDEX IF NOT EXISTS idx_file_type ON events(file_type);
+	CREATE INDEX IF NOT EXISTS idx_file_path ON events(file_path);
+	CREATE TABLE IF NOT EXISTS overflow_stats (
+		id BIGSERIAL PRIMARY KEY,
+		timestamp TIMESTAMPTZ NOT NULL,
+		policy TEXT NOT NULL,
+		dropped BIGINT NOT NULL,
+		coalesced BIGINT NOT NULL,
+		kernel_overflows BIGINT NOT NULL
+	);
+	`
+
+var postgresDialect = dialect{
+	name:   "postgres",
+	schema: postgresSchema,
+	placeholder: func(n int) string {
+		return fmt.Sprintf("$%d", n)
+	},
+	regexOp:         "~",
+	columnExistsSQL: `SELECT COUNT(*) FROM information_schema.columns WHERE table_name = ? AND column_name = ?`,
+	minuteBucket:    `to_char(timestamp AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI')`,
+	hourBucket:      `to_char(timestamp AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24')`,
+	// Watchers starting together may both find a column missing
+	addColumnSQL: `ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`,
+	lateCommits:  true,
+}
+
+// postgresDriver is the database/sql driver used for PostgreSQL; tests swap
+// in a stand-in.
+var postgresDriver = "postgres"
+
+// postgresKeywords are the libpq connection keywords that mark a key=value
+// DSN such as "host=db.example.com dbname=fstimeline".
+var postgresKeywords = map[string]bool{
+	"host": true, "hostaddr": true, "port": true, "dbname": true, "user": true,
+	"password": true, "sslmode": true, "connect_timeout": true, "application_name": true,
+}
+
+// isPostgresDSN reports whether dsn is a postgres:// or postgresql:// URL or
+// a libpq key=value DSN whose keywords are all known.
+func isPostgresDSN(dsn string) bool {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return true
+	}
+
+	fields := strings.Fields(dsn)
+	for _, field := range fields {
+		key, _, ok := strings.Cut(field, "=")
+		if !ok || !postgresKeywords[key] {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+// readOnlyDSN returns dsn with every transaction marked read-only.
+func readOnlyDSN(dsn string) (string, error) {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " default_transaction_read_only=on", nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+	params := u.Query()
+	params.Set("default_transaction_read_only", "on")
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+// NewPostgres connects to a PostgreSQL server, creating the schema if needed.
+// Several watchers on different machines can share one database.
+func NewPostgres(dsn string) (*DB, error) {
+	conn, err := sql.Open(postgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	db := &DB{conn: conn, dialect: postgresDialect}
+	if err := db.createSchema(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// NewPostgresReadOnly connects with every transaction marked read-only.
+func NewPostgresReadOnly(dsn string) (*DB, error) {
+	readOnly, err := readOnlyDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open(postgresDriver, readOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	db := &DB{conn: conn, dialect: postgresDialect}
+	if err := upgradeReadOnly(db, func() (*DB, error) { return NewPostgres(dsn) }); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
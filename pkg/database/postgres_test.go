@@ -0,0 +1,352 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standinDriver stands in for a PostgreSQL server so the postgres dialect can
+// be tested without one. It rewrites the dialect's SQL for SQLite and runs it
+// on the file named by the path of a postgres://standin/<path> URL. As on a
+// server, default_transaction_read_only=on makes the connection refuse
+// writes.
+type standinDriver struct {
+	sqlite driver.Driver
+}
+
+func init() {
+	db, err := sql.Open(sqliteDriver, "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register("postgres_standin", standinDriver{sqlite: db.Driver()})
+}
+
+func (d standinDriver) Open(name string) (driver.Conn, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	params := sqliteWriteParams()
+	if u.Query().Get("default_transaction_read_only") == "on" {
+		params = sqliteReadParams()
+	}
+
+	conn, err := d.sqlite.Open(dsn(u.Path, params))
+	if err != nil {
+		return nil, err
+	}
+	return standinConn{conn}, nil
+}
+
+var standinRewrites = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`BIGSERIAL PRIMARY KEY`), `INTEGER PRIMARY KEY AUTOINCREMENT`},
+	{regexp.MustCompile(`TIMESTAMPTZ`), `DATETIME`},
+	{regexp.MustCompile(`information_schema\.columns WHERE table_name = (\$\d+) AND column_name = (\$\d+)`),
+		`pragma_table_info(${1}) WHERE name = ${2}`},
+	{regexp.MustCompile(`to_char\(timestamp AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI'\)`), `substr(timestamp, 1, 16)`},
+	{regexp.MustCompile(`to_char\(timestamp AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24'\)`), `substr(timestamp, 1, 13)`},
+	{regexp.MustCompile(`ADD COLUMN IF NOT EXISTS`), `ADD COLUMN`},
+	{regexp.MustCompile(` ~ `), ` REGEXP `},
+	{regexp.MustCompile(`\$(\d+)`), `?${1}`},
+}
+
+func toSQLite(query string) string {
+	for _, rewrite := range standinRewrites {
+		query = rewrite.pattern.ReplaceAllString(query, rewrite.replacement)
+	}
+	return query
+}
+
+type standinConn struct {
+	driver.Conn
+}
+
+func (c standinConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(toSQLite(query))
+}
+
+func (c standinConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, toSQLite(query), args)
+}
+
+func (c standinConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, toSQLite(query), args)
+}
+
+func (c standinConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// standinURL returns the URL of a new stand-in database and routes
+// PostgreSQL connections to the stand-in for the rest of the test.
+func standinURL(t *testing.T) string {
+	t.Helper()
+	previous := postgresDriver
+	postgresDriver = "postgres_standin"
+	t.Cleanup(func() { postgresDriver = previous })
+
+	return "postgres://standin" + filepath.ToSlash(filepath.Join(t.TempDir(), "pg.db")) + "?sslmode=disable"
+}
+
+func openStandin(t *testing.T, url string) *DB {
+	t.Helper()
+	db, err := NewPostgres(url)
+	if err != nil {
+		t.Fatalf("NewPostgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect dialect
+		query   string
+		want    string
+	}{
+		{sqliteDialect, `SELECT * FROM events WHERE id > ? AND file_path = ?`, `SELECT * FROM events WHERE id > ? AND file_path = ?`},
+		{postgresDialect, `SELECT * FROM events WHERE id > ? AND file_path = ?`, `SELECT * FROM events WHERE id > $1 AND file_path = $2`},
+		{postgresDialect, `SELECT 1`, `SELECT 1`},
+		{postgresDialect, `INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			`INSERT INTO t VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`},
+		{postgresDialect, `SELECT 'é' || ?`, `SELECT 'é' || $1`},
+	}
+
+	for _, tt := range tests {
+		db := &DB{dialect: tt.dialect}
+		if got := db.rebind(tt.query); got != tt.want {
+			t.Errorf("%s rebind(%q) = %q, want %q", tt.dialect.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPostgresDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		postgres bool
+		readOnly string
+	}{
+		{"postgres://u:p@db/fstimeline", true, "postgres://u:p@db/fstimeline?default_transaction_read_only=on"},
+		{"postgresql://db/fstimeline?sslmode=disable", true, "postgresql://db/fstimeline?default_transaction_read_only=on&sslmode=disable"},
+		{"host=db.example.com dbname=fstimeline user=fstimeline", true,
+			"host=db.example.com dbname=fstimeline user=fstimeline default_transaction_read_only=on"},
+		{"dbname=fstimeline", true, "dbname=fstimeline default_transaction_read_only=on"},
+		{"fstimeline.db", false, ""},
+		{"/data/run=1/fstimeline.db", false, ""},
+		{"mode=ro.db", false, ""},
+		{"", false, ""},
+	}
+
+	for _, tt := range tests {
+		if got := isPostgresDSN(tt.dsn); got != tt.postgres {
+			t.Errorf("isPostgresDSN(%q) = %v, want %v", tt.dsn, got, tt.postgres)
+		}
+		if !tt.postgres {
+			continue
+		}
+		if got, err := readOnlyDSN(tt.dsn); err != nil || got != tt.readOnly {
+			t.Errorf("readOnlyDSN(%q) = %q, %v; want %q", tt.dsn, got, err, tt.readOnly)
+		}
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	db := openStandin(t, standinURL(t))
+	if db.CommitsInIDOrder() {
+		t.Error("PostgreSQL reported as committing in id order")
+	}
+
+	session := &Session{Hostname: "host", Path: "/src", StartedAt: testStart}
+	if err := db.StartSession(session); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if session.ID == 0 {
+		t.Fatal("StartSession did not set the id")
+	}
+
+	events := testEvents()
+	for _, event := range events[:4] {
+		event.SessionID = &session.ID
+	}
+	if err := db.InsertEvents(events); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+
+	all, err := db.QueryEvents(QueryFilter{Ascending: true})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(all) != 6 || !all[0].Timestamp.Equal(testStart) || all[0].Size == nil || *all[0].Size != 1234 {
+		t.Fatalf("events = %v", paths(all))
+	}
+	if all[0].SessionID == nil || *all[0].SessionID != session.ID || all[5].SessionID != nil {
+		t.Errorf("session ids = %v, %v", all[0].SessionID, all[5].SessionID)
+	}
+
+	// Globs are matched as regular expressions on PostgreSQL
+	start := testStart.Add(time.Minute)
+	for _, filter := range []QueryFilter{
+		{PathGlob: "/src/*/*.go", StartTime: &start},
+		{PathRegex: `\.go$`, EventTypes: []string{"WRITE"}},
+		{After: CursorOf(all[0]), Limit: 2, Ascending: true},
+	} {
+		events, err := db.QueryEvents(filter)
+		if err != nil {
+			t.Fatalf("QueryEvents: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("%+v: got %v, want 2 events", filter, paths(events))
+		}
+	}
+
+	histogram, err := db.Histogram(QueryFilter{}, HistogramOptions{Interval: IntervalHour})
+	if err != nil {
+		t.Fatalf("Histogram: %v", err)
+	}
+	if len(histogram.Buckets) != 3 || histogram.Series[0].Counts[0] != 4 {
+		t.Errorf("histogram = %+v", histogram)
+	}
+
+	stats, err := db.Stats(QueryFilter{}, 3)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.TotalEvents != 6 || stats.FirstEvent == nil || !stats.FirstEvent.Equal(testStart) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	results, err := db.SearchPaths([]string{"conf"}, QueryFilter{}, 0)
+	if err != nil {
+		t.Fatalf("SearchPaths: %v", err)
+	}
+	if len(results) != 1 || results[0].FilePath != "/src/app/config.yaml" {
+		t.Errorf("search = %+v", results)
+	}
+
+	ended := testStart.Add(time.Hour)
+	session.EndedAt = &ended
+	if err := db.EndSession(session); err != nil {
+		t.Fatalf("EndSession: %v", err)
+	}
+	sessions, err := db.QuerySessions(0)
+	if err != nil {
+		t.Fatalf("QuerySessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Events != 4 || sessions[0].EndedAt == nil || !sessions[0].EndedAt.Equal(ended) {
+		t.Errorf("sessions = %+v", sessions[0])
+	}
+}
+
+func TestPostgresConcurrentWriters(t *testing.T) {
+	url := standinURL(t)
+	stores := []*DB{openStandin(t, url), openStandin(t, url)}
+
+	const writers, batches, batchSize = 4, 10, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*batches)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			db := stores[writer%len(stores)]
+
+			session := &Session{Hostname: fmt.Sprintf("host%d", writer), Path: "/src", StartedAt: testStart}
+			if err := db.StartSession(session); err != nil {
+				errs <- err
+				return
+			}
+			for b := 0; b < batches; b++ {
+				var events []*Event
+				for e := 0; e < batchSize; e++ {
+					event := testEvent(time.Duration(b*batchSize+e)*time.Second, "WRITE", fmt.Sprintf("/src/w%d/f%d", writer, e))
+					event.SessionID = &session.ID
+					events = append(events, event)
+				}
+				if err := db.InsertEvents(events); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent write: %v", err)
+	}
+
+	ids := make(map[int64]bool)
+	err := stores[0].IterateEvents(QueryFilter{}, func(event *Event) error {
+		ids[event.ID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateEvents: %v", err)
+	}
+	if len(ids) != writers*batches*batchSize {
+		t.Errorf("got %d distinct events, want %d", len(ids), writers*batches*batchSize)
+	}
+
+	sessions, err := stores[1].QuerySessions(0)
+	if err != nil {
+		t.Fatalf("QuerySessions: %v", err)
+	}
+	if len(sessions) != writers {
+		t.Fatalf("got %d sessions, want %d", len(sessions), writers)
+	}
+	for _, session := range sessions {
+		if session.Events != batches*batchSize {
+			t.Errorf("session %d recorded %d events, want %d", session.ID, session.Events, batches*batchSize)
+		}
+	}
+}
+
+func TestPostgresReadOnly(t *testing.T) {
+	url := standinURL(t)
+	db := openStandin(t, url)
+	if err := db.InsertEvents(testEvents()); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+
+	// Simulate a database from before sessions were linked to events
+	for _, query := range []string{`DROP INDEX idx_session_id`, `ALTER TABLE events DROP COLUMN session_id`} {
+		if _, err := db.conn.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	db.Close()
+
+	readOnly, err := NewPostgresReadOnly(url)
+	if err != nil {
+		t.Fatalf("NewPostgresReadOnly: %v", err)
+	}
+	defer readOnly.Close()
+
+	if outdated, err := readOnly.schemaOutdated(); err != nil || outdated {
+		t.Errorf("schema not upgraded: outdated %v, %v", outdated, err)
+	}
+
+	events, err := readOnly.QueryEvents(QueryFilter{})
+	if err != nil || len(events) != 6 {
+		t.Fatalf("QueryEvents = %d events, %v", len(events), err)
+	}
+
+	if err := readOnly.InsertEvents(testEvents()[:1]); err == nil {
+		t.Error("read-only connection accepted a write")
+	}
+	if err := readOnly.StartSession(&Session{Hostname: "host", Path: "/", StartedAt: testStart}); err == nil {
+		t.Error("read-only connection started a session")
+	}
+}
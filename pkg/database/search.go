@@ -0,0 +1,158 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The full-text index is a separate FTS5 table holding a copy of each
+// event's path and name under the event's id. It is only available on SQLite
+// builds that include FTS5 (the pure-Go driver, or the CGO driver built with
+// the sqlite_fts5 tag); elsewhere search falls back to LIKE matching.
+const (
+	createFTSSQL = `CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(file_path, file_name)`
+	indexFTSSQL  = `INSERT INTO events_fts (rowid, file_path, file_name)
+		SELECT id, file_path, file_name FROM events
+		WHERE id > COALESCE((SELECT rowid FROM events_fts ORDER BY rowid DESC LIMIT 1), 0)`
+)
+
+// setupFTS creates the full-text index if this build supports it and indexes
+// any events written by builds that do not. A database indexed by another
+// build is still opened by a build without FTS5; it then searches with LIKE
+// and the index catches up the next time an FTS5 build writes.
+func (db *DB) setupFTS() error {
+	if !db.hasFTS5() {
+		return nil
+	}
+
+	if _, err := db.conn.Exec(createFTSSQL); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	if _, err := db.conn.Exec(indexFTSSQL); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+
+	db.fts = true
+	return nil
+}
+
+// detectFTS enables full-text search on a read-only connection when this
+// build can read the index and it covers every event. The index falls behind
+// while only builds without FTS5 write to the database.
+func (db *DB) detectFTS() {
+	if !db.hasFTS5() {
+		return
+	}
+
+	var behind bool
+	err := db.conn.QueryRow(`SELECT COALESCE((SELECT MAX(id) FROM events), 0) >
+		COALESCE((SELECT rowid FROM events_fts ORDER BY rowid DESC LIMIT 1), 0)`).Scan(&behind)
+	if err != nil || behind {
+		return
+	}
+	db.fts = true
+}
+
+// hasFTS5 reports whether this build's SQLite has the fts5 module.
+func (db *DB) hasFTS5() bool {
+	if db.dialect.name != "sqlite" {
+		return false
+	}
+
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM pragma_module_list WHERE name = 'fts5'`).Scan(&count)
+	return err == nil && count > 0
+}
+
+// SearchResult is one file matching a search, with its activity summary.
+type SearchResult struct {
+	FilePath string
+	Events   int64
+	LastSeen time.Time
+	// Rank orders results; lower is better.
+	Rank float64
+}
+
+// SearchPaths finds files whose path matches every term, best matches first.
+// Terms match the start of any word in the path, so "conf" finds
+// "app/config.yaml".
+func (db *DB) SearchPaths(terms []string, filter QueryFilter, limit int) ([]*SearchResult, error) {
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("no search terms given")
+	}
+
+	where, whereArgs := db.where(filter)
+	var query string
+	var args []interface{}
+
+	if db.fts {
+		// The ranking must be computed before grouping; MATERIALIZED stops
+		// SQLite from folding bm25() into the aggregate, where it is invalid.
+		query = `WITH ranked AS MATERIALIZED (
+			SELECT rowid AS event_id, bm25(events_fts, 1.0, 2.0) AS rank
+			FROM events_fts WHERE events_fts MATCH ?
+		)
+		SELECT file_path, COUNT(*), MAX(timestamp), MIN(rank)
+		FROM events JOIN ranked ON events.id = ranked.event_id
+		WHERE ` + where + `
+		GROUP BY file_path ORDER BY MIN(rank), MAX(timestamp) DESC`
+		args = append(args, ftsQuery(terms))
+		args = append(args, whereArgs...)
+	} else {
+		// Without an index, rank by how many terms appear in the file name.
+		var score []string
+		for _, term := range terms {
+			score = append(score, "CASE WHEN file_name LIKE ? ESCAPE '\\' THEN 1 ELSE 0 END")
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		query = `SELECT file_path, COUNT(*), MAX(timestamp), -MAX(` + strings.Join(score, " + ") + `)
+		FROM events WHERE ` + where
+		args = append(args, whereArgs...)
+		for _, term := range terms {
+			query += " AND LOWER(file_path) LIKE ? ESCAPE '\\'"
+			args = append(args, "%"+escapeLike(strings.ToLower(term))+"%")
+		}
+		query += " GROUP BY file_path ORDER BY 4, 3 DESC"
+	}
+
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.Query(db.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search events: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		result := &SearchResult{}
+		var lastSeen interface{}
+		if err := rows.Scan(&result.FilePath, &result.Events, &lastSeen, &result.Rank); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if result.LastSeen, err = parseDBTime(lastSeen); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// ftsQuery turns terms into an FTS5 query requiring a prefix match of each.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
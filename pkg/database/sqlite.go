@@ -0,0 +1,249 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		event_type TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		file_type TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		size INTEGER,
+		inode INTEGER,
+		mode INTEGER,
+		uid INTEGER,
+		gid INTEGER,
+		mtime INTEGER,
+		atime INTEGER,
+		ctime INTEGER,
+		btime INTEGER,
+		session_id INTEGER REFERENCES sessions(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_timestamp ON events(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_directory ON events(directory);
+	CREATE INDEX IF NOT EXISTS idx_file_type ON events(file_type);
+	CREATE INDEX IF NOT EXISTS idx_file_path ON events(file_path);
+	CREATE TABLE IF NOT EXISTS overflow_stats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		policy TEXT NOT NULL,
+		dropped INTEGER NOT NULL,
+		coalesced INTEGER NOT NULL,
+		kernel_overflows INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hostname TEXT NOT NULL,
+		path TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME
+	);
+	`
+
+var sqliteDialect = dialect{
+	name:            "sqlite",
+	schema:          sqliteSchema,
+	nativeGlob:      true,
+	regexOp:         "REGEXP",
+	columnExistsSQL: `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`,
+	addColumnSQL:    `ALTER TABLE %s ADD COLUMN %s %s`,
+	// Timestamps are stored as UTC text starting "2006-01-02 15:04"
+	minuteBucket: `substr(timestamp, 1, 16)`,
+	hourBucket:   `substr(timestamp, 1, 13)`,
+}
+
+// sqliteUTCVersion is the user_version from which every timestamp is stored
+// in UTC. Earlier versions stored them with the writer's local offset, which
+// breaks comparing them as text and the substr() buckets above.
+const sqliteUTCVersion = 1
+
+// timestampColumns are the DATETIME columns rewritten by migrateToUTC.
+var timestampColumns = []struct{ table, name string }{
+	{"events", "timestamp"},
+	{"overflow_stats", "timestamp"},
+	{"sessions", "started_at"},
+	{"sessions", "ended_at"},
+}
+
+func (db *DB) sqliteVersion() (int, error) {
+	var version int
+	if err := db.conn.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// migrateToUTC rewrites timestamps stored with a local offset in UTC, once.
+func (db *DB) migrateToUTC() error {
+	version, err := db.sqliteVersion()
+	if err != nil || version >= sqliteUTCVersion {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, column := range timestampColumns {
+		// Read the rows first, as the only connection is busy until they are
+		rows, err := tx.Query(fmt.Sprintf(`SELECT id, %[2]s FROM %[1]s
+			WHERE %[2]s IS NOT NULL AND %[2]s NOT LIKE '%%+00:00'`, column.table, column.name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s.%s: %w", column.table, column.name, err)
+		}
+
+		var ids []int64
+		var times []time.Time
+		for rows.Next() {
+			var id int64
+			var value interface{}
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read %s.%s: %w", column.table, column.name, err)
+			}
+			t, err := parseDBTime(value)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read %s.%s: %w", column.table, column.name, err)
+			}
+			ids = append(ids, id)
+			times = append(times, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read %s.%s: %w", column.table, column.name, err)
+		}
+
+		update := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE id = ?`, column.table, column.name)
+		for i, id := range ids {
+			if _, err := tx.Exec(update, dbTime(times[i]), id); err != nil {
+				return fmt.Errorf("failed to convert %s.%s to UTC: %w", column.table, column.name, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, sqliteUTCVersion)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SQLite parses "x REGEXP y" but leaves the function to the application.
+// Both drivers register sqliteRegexp under the name regexp.
+var regexCache sync.Map
+
+func sqliteRegexp(pattern, value string) (bool, error) {
+	cached, ok := regexCache.Load(pattern)
+	if !ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		cached, _ = regexCache.LoadOrStore(pattern, re)
+	}
+
+	return cached.(*regexp.Regexp).MatchString(value), nil
+}
+
+// busyTimeout is how long a connection waits for a lock held by another
+// process before failing with "database is locked".
+const busyTimeout = 5 * time.Second
+
+// New opens the database for writing, creating the schema and the search
+// index if needed. The database is switched to WAL journaling so readers
+// never block the writer.
+func New(dbPath string) (*DB, error) {
+	db, err := openWritable(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.setupFTS(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// openWritable opens the database for writing and brings its schema up to
+// date, leaving the search index alone.
+func openWritable(dbPath string) (*DB, error) {
+	conn, err := sql.Open(sqliteDriver, dsn(dbPath, sqliteWriteParams()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection avoids lock contention
+	// between our own pooled connections.
+	conn.SetMaxOpenConns(1)
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	db := &DB{conn: conn, dialect: sqliteDialect}
+	if err := db.createSchema(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// NewReadOnly opens an existing database for querying. It can be used while
+// another process is writing to the same file.
+func NewReadOnly(dbPath string) (*DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn, err := sql.Open(sqliteDriver, dsn(dbPath, sqliteReadParams()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn.SetMaxOpenConns(runtime.NumCPU())
+	conn.SetMaxIdleConns(2)
+	conn.SetConnMaxIdleTime(time.Minute)
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	db := &DB{conn: conn, dialect: sqliteDialect}
+	if err := upgradeReadOnly(db, func() (*DB, error) { return openWritable(dbPath) }); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	db.detectFTS()
+
+	return db, nil
+}
+
+func dsn(dbPath string, params url.Values) string {
+	path := (&url.URL{Path: dbPath}).EscapedPath()
+	return "file:" + path + "?" + params.Encode()
+}
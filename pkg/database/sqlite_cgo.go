@@ -0,0 +1,37 @@
+//go:build cgo && !sqlite_purego
+
+package database
+
+import (
+	"database/sql"
+	"net/url"
+	"strconv"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteDriver = "sqlite3_fstimeline"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", sqliteRegexp, true)
+		},
+	})
+}
+
+func sqliteWriteParams() url.Values {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	params.Set("_txlock", "immediate")
+	return params
+}
+
+func sqliteReadParams() url.Values {
+	params := url.Values{}
+	params.Set("mode", "ro")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	return params
+}
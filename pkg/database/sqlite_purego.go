@@ -0,0 +1,44 @@
+//go:build !cgo || sqlite_purego
+
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+
+	"modernc.org/sqlite"
+)
+
+// The pure-Go driver is used when building without CGO or with the
+// sqlite_purego tag. It reads and writes the same file format and stores
+// timestamps in the same text layout as the CGO driver, so databases can be
+// shared between the two builds; TestSharedDatabase checks this.
+const sqliteDriver = "sqlite"
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			pattern, _ := args[0].(string)
+			value, _ := args[1].(string)
+			return sqliteRegexp(pattern, value)
+		})
+}
+
+func sqliteWriteParams() url.Values {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+	return params
+}
+
+func sqliteReadParams() url.Values {
+	params := url.Values{}
+	params.Set("mode", "ro")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Set("_time_format", "sqlite")
+	return params
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stats summarizes the events matching a filter. The fields after
+// ByEventType are only filled in when Stats is asked for a top N.
+type Stats struct {
+	TotalEvents int64            `json:"total_events"`
+	FirstEvent  *time.Time       `json:"first_event,omitempty"`
+	LastEvent   *time.Time       `json:"last_event,omitempty"`
+	ByEventType map[string]int64 `json:"by_event_type"`
+
+	TopFileTypes   []Count `json:"top_file_types,omitempty"`
+	TopDirectories []Count `json:"top_directories,omitempty"`
+	TopFiles       []Count `json:"top_files,omitempty"`
+	// ByHour and ByWeekday are indexed by local hour of day and by
+	// time.Weekday, starting on Sunday.
+	ByHour    []int64 `json:"by_hour,omitempty"`
+	ByWeekday []int64 `json:"by_weekday,omitempty"`
+}
+
+// Count is a value with the number of events it occurs in.
+type Count struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// Stats counts the events matching filter. When top is positive it also
+// finds the top file types, directories and files, and counts the hours and
+// weekdays the events happened on.
+func (db *DB) Stats(filter QueryFilter, top int) (*Stats, error) {
+	where, args := db.where(filter)
+	stats := &Stats{ByEventType: make(map[string]int64)}
+
+	byEventType, err := db.countBy("event_type", where, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range byEventType {
+		stats.ByEventType[count.Key] = count.Count
+		stats.TotalEvents += count.Count
+	}
+
+	if stats.TotalEvents == 0 {
+		return stats, nil
+	}
+
+	// Selecting the column itself rather than MIN/MAX keeps its declared type,
+	// so the driver returns a time.Time on every backend.
+	var first, last time.Time
+	if err := db.conn.QueryRow(db.rebind(`SELECT timestamp FROM events WHERE `+where+
+		` ORDER BY timestamp ASC LIMIT 1`), args...).Scan(&first); err != nil {
+		return nil, fmt.Errorf("failed to query first event: %w", err)
+	}
+	if err := db.conn.QueryRow(db.rebind(`SELECT timestamp FROM events WHERE `+where+
+		` ORDER BY timestamp DESC LIMIT 1`), args...).Scan(&last); err != nil {
+		return nil, fmt.Errorf("failed to query last event: %w", err)
+	}
+	first, last = first.Local(), last.Local()
+	stats.FirstEvent = &first
+	stats.LastEvent = &last
+
+	if top <= 0 {
+		return stats, nil
+	}
+
+	if stats.TopFileTypes, err = db.countBy("file_type", where, args, top); err != nil {
+		return nil, err
+	}
+	if stats.TopDirectories, err = db.countBy("directory", where, args, top); err != nil {
+		return nil, err
+	}
+	if stats.TopFiles, err = db.countBy("file_path", where, args, top); err != nil {
+		return nil, err
+	}
+
+	// Hours are counted in UTC by the database and moved to the local time
+	// zone here, since the backends disagree on time zone handling. Zones
+	// that are not a whole number of hours from UTC are counted by minute.
+	minutes := !hourAligned(first, last.Add(time.Hour))
+	buckets, err := db.bucketCounts(filter, "", minutes)
+	if err != nil {
+		return nil, err
+	}
+	stats.ByHour = make([]int64, 24)
+	stats.ByWeekday = make([]int64, 7)
+	for _, bucket := range buckets {
+		start := bucket.start.Local()
+		stats.ByHour[start.Hour()] += bucket.count
+		stats.ByWeekday[start.Weekday()] += bucket.count
+	}
+
+	return stats, nil
+}
+
+// countBy counts matching events per value of expr, most frequent first,
+// keeping only the first limit values when limit is positive.
+func (db *DB) countBy(expr, where string, args []interface{}, limit int) ([]Count, error) {
+	query := `SELECT ` + expr + `, COUNT(*) FROM events WHERE ` + where +
+		` GROUP BY ` + expr + ` ORDER BY COUNT(*) DESC, ` + expr
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args[:len(args):len(args)], limit)
+	}
+
+	rows, err := db.conn.Query(db.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []Count
+	for rows.Next() {
+		var count Count
+		if err := rows.Scan(&count.Key, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan stats: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return counts, nil
+}
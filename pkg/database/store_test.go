@@ -0,0 +1,569 @@
+package database
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// These tests run against whichever SQLite driver the package is built with.
+// Run them under both builds:
+//
+//	go test ./pkg/database
+//	CGO_ENABLED=0 go test ./pkg/database
+
+var testStart = time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+
+func testEvent(offset time.Duration, eventType, filePath string) *Event {
+	fileType := strings.TrimPrefix(path.Ext(filePath), ".")
+	if fileType == "" {
+		fileType = "no-extension"
+	}
+	return &Event{
+		Timestamp: testStart.Add(offset),
+		EventType: eventType,
+		FilePath:  filePath,
+		FileName:  path.Base(filePath),
+		FileType:  fileType,
+		Directory: path.Dir(filePath),
+	}
+}
+
+func testEvents() []*Event {
+	size, inode, mode, uid := int64(1234), int64(98765), int64(0o100644), int64(1000)
+	mtime := testStart.Add(-time.Hour)
+
+	created := testEvent(0, "CREATE", "/src/app/main.go")
+	created.Size, created.Inode, created.Mode, created.UID, created.ModTime = &size, &inode, &mode, &uid, &mtime
+
+	return []*Event{
+		created,
+		testEvent(time.Minute, "WRITE", "/src/app/main.go"),
+		testEvent(2*time.Minute, "CREATE", "/src/app/config.yaml"),
+		testEvent(2*time.Minute, "WRITE", "/src/app/config.yaml"),
+		testEvent(time.Hour, "WRITE", "/src/lib/util.go"),
+		testEvent(2*time.Hour, "REMOVE", "/tmp/scratch"),
+	}
+}
+
+func openTestDB(t *testing.T, dbPath string) *DB {
+	t.Helper()
+	db, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	if err := db.InsertEvents(testEvents()); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+	return db
+}
+
+func paths(events []*Event) []string {
+	var paths []string
+	for _, event := range events {
+		paths = append(paths, event.EventType+" "+event.FilePath)
+	}
+	return paths
+}
+
+func TestInsertAndQueryEvents(t *testing.T) {
+	db := newTestDB(t)
+
+	events, err := db.QueryEvents(QueryFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	want := []string{
+		"REMOVE /tmp/scratch",
+		"WRITE /src/lib/util.go",
+		"WRITE /src/app/config.yaml",
+		"CREATE /src/app/config.yaml",
+		"WRITE /src/app/main.go",
+		"CREATE /src/app/main.go",
+	}
+	if got := paths(events); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events newest first:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	first := events[len(events)-1]
+	fixture := testEvents()[0]
+	if !first.Timestamp.Equal(fixture.Timestamp) {
+		t.Errorf("timestamp = %v, want %v", first.Timestamp, fixture.Timestamp)
+	}
+	if first.Size == nil || *first.Size != 1234 || first.Inode == nil || *first.Inode != 98765 ||
+		first.Mode == nil || *first.Mode != 0o100644 || first.UID == nil || *first.UID != 1000 {
+		t.Errorf("metadata not kept: size %v inode %v mode %v uid %v", first.Size, first.Inode, first.Mode, first.UID)
+	}
+	if first.ModTime == nil || !first.ModTime.Equal(*fixture.ModTime) {
+		t.Errorf("mtime = %v, want %v", first.ModTime, fixture.ModTime)
+	}
+	if first.GID != nil || first.BirthTime != nil || events[0].Size != nil {
+		t.Errorf("missing metadata read back as set")
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	db := newTestDB(t)
+	start, end := testStart.Add(time.Minute), testStart.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   int
+	}{
+		{"time range", QueryFilter{StartTime: &start, EndTime: &end}, 4},
+		{"event types", QueryFilter{EventTypes: []string{"CREATE"}}, 2},
+		{"exclude event types", QueryFilter{ExcludeEventTypes: []string{"WRITE"}}, 3},
+		{"file types", QueryFilter{FileTypes: []string{"go"}}, 3},
+		{"directory", QueryFilter{Directory: "/src"}, 5},
+		{"exclude directories", QueryFilter{ExcludeDirectories: []string{"/src/app"}}, 2},
+		{"file path", QueryFilter{FilePath: "/src/app/main.go"}, 2},
+		{"glob", QueryFilter{PathGlob: "/src/*/*.go"}, 3},
+		{"regex", QueryFilter{PathRegex: `\.ya?ml$`}, 2},
+		{"name contains", QueryFilter{NameContains: "conf"}, 2},
+		{"limit", QueryFilter{Limit: 2}, 2},
+		{"after id", QueryFilter{AfterID: 4}, 2},
+		{"up to id", QueryFilter{UpToID: 4}, 4},
+		{"id range", QueryFilter{AfterID: 2, UpToID: 4}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := db.QueryEvents(tt.filter)
+			if err != nil {
+				t.Fatalf("QueryEvents: %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("got %d events, want %d:\n%s", len(events), tt.want, strings.Join(paths(events), "\n"))
+			}
+		})
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, ascending := range []bool{false, true} {
+		all, err := db.QueryEvents(QueryFilter{Ascending: ascending})
+		if err != nil {
+			t.Fatalf("QueryEvents: %v", err)
+		}
+
+		var paged []*Event
+		filter := QueryFilter{Ascending: ascending, Limit: 2}
+		for {
+			page, err := db.QueryEvents(filter)
+			if err != nil {
+				t.Fatalf("QueryEvents: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			paged = append(paged, page...)
+
+			// Round-trip the cursor as the command line does
+			cursor, err := ParseCursor(CursorOf(page[len(page)-1]).String())
+			if err != nil {
+				t.Fatalf("ParseCursor: %v", err)
+			}
+			if ascending {
+				filter.After = cursor
+			} else {
+				filter.Before = cursor
+			}
+		}
+
+		if got, want := paths(paged), paths(all); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("ascending=%v: pages give\n%s\nwant\n%s", ascending, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestIterateAndLatestEventID(t *testing.T) {
+	db := newTestDB(t)
+
+	latest, err := db.LatestEventID()
+	if err != nil {
+		t.Fatalf("LatestEventID: %v", err)
+	}
+	if latest != 6 {
+		t.Errorf("LatestEventID = %d, want 6", latest)
+	}
+	if !db.CommitsInIDOrder() {
+		t.Error("SQLite reported as committing out of id order")
+	}
+
+	var ids []int64
+	err = db.IterateEvents(QueryFilter{Ascending: true}, func(event *Event) error {
+		ids = append(ids, event.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateEvents: %v", err)
+	}
+	if len(ids) != 6 || ids[0] != 1 || ids[5] != 6 {
+		t.Errorf("ids oldest first = %v", ids)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	db := newTestDB(t)
+
+	results, err := db.SearchPaths([]string{"conf"}, QueryFilter{}, 0)
+	if err != nil {
+		t.Fatalf("SearchPaths: %v", err)
+	}
+	if len(results) != 1 || results[0].FilePath != "/src/app/config.yaml" || results[0].Events != 2 {
+		t.Fatalf("search conf = %+v", results)
+	}
+	if want := testStart.Add(2 * time.Minute); !results[0].LastSeen.Equal(want) {
+		t.Errorf("last seen = %v, want %v", results[0].LastSeen, want)
+	}
+
+	results, err = db.SearchPaths([]string{"src", "go"}, QueryFilter{EventTypes: []string{"WRITE"}}, 0)
+	if err != nil {
+		t.Fatalf("SearchPaths: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("search src go = %d results, want 2", len(results))
+	}
+}
+
+func TestStatsAndHistogram(t *testing.T) {
+	db := newTestDB(t)
+
+	stats, err := db.Stats(QueryFilter{}, 3)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.TotalEvents != 6 || stats.ByEventType["WRITE"] != 3 || stats.ByEventType["CREATE"] != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.FirstEvent == nil || !stats.FirstEvent.Equal(testStart) {
+		t.Errorf("first event = %v, want %v", stats.FirstEvent, testStart)
+	}
+	if stats.LastEvent == nil || !stats.LastEvent.Equal(testStart.Add(2*time.Hour)) {
+		t.Errorf("last event = %v", stats.LastEvent)
+	}
+	if len(stats.TopDirectories) == 0 || stats.TopDirectories[0].Key != "/src/app" || stats.TopDirectories[0].Count != 4 {
+		t.Errorf("top directories = %+v", stats.TopDirectories)
+	}
+
+	histogram, err := db.Histogram(QueryFilter{}, HistogramOptions{Interval: IntervalHour})
+	if err != nil {
+		t.Fatalf("Histogram: %v", err)
+	}
+	if len(histogram.Buckets) != 3 || len(histogram.Series) != 1 {
+		t.Fatalf("histogram = %+v", histogram)
+	}
+	if counts := histogram.Series[0].Counts; counts[0] != 4 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("hourly counts = %v, want [4 1 1]", counts)
+	}
+}
+
+func TestStatsAndHistogramHalfHourZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("IST", 5*60*60+30*60)
+	t.Cleanup(func() { time.Local = local })
+
+	// 14:40, 14:50, 15:10 and 15:20 local time; UTC hours would put them
+	// all in one hour
+	db := openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+	var events []*Event
+	for _, minutes := range []int{10, 20, 40, 50} {
+		events = append(events, testEvent(time.Duration(minutes)*time.Minute, "WRITE", "/src/main.go"))
+	}
+	if err := db.InsertEvents(events); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+
+	stats, err := db.Stats(QueryFilter{}, 3)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.ByHour[14] != 2 || stats.ByHour[15] != 2 {
+		t.Errorf("by hour = %v, want 2 at 14 and 15", stats.ByHour)
+	}
+
+	histogram, err := db.Histogram(QueryFilter{}, HistogramOptions{Interval: IntervalHour})
+	if err != nil {
+		t.Fatalf("Histogram: %v", err)
+	}
+	if len(histogram.Buckets) != 2 || histogram.Buckets[0].Hour() != 14 || histogram.Buckets[0].Minute() != 0 {
+		t.Fatalf("buckets = %v", histogram.Buckets)
+	}
+	if counts := histogram.Series[0].Counts; counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("hourly counts = %v, want [2 2]", counts)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := newTestDB(t)
+
+	removed, err := db.Prune(testStart.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 4 {
+		t.Errorf("pruned %d events, want 4", removed)
+	}
+
+	results, err := db.SearchPaths([]string{"main"}, QueryFilter{}, 0)
+	if err != nil {
+		t.Fatalf("SearchPaths: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("pruned file still found: %+v", results)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "test.db"))
+
+	session := &Session{Hostname: "host", Path: "/src", StartedAt: testStart}
+	if err := db.StartSession(session); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if session.ID == 0 {
+		t.Fatal("StartSession did not set the id")
+	}
+	ended := testStart.Add(time.Hour)
+	session.EndedAt = &ended
+	if err := db.EndSession(session); err != nil {
+		t.Fatalf("EndSession: %v", err)
+	}
+
+	sessions, err := db.QuerySessions(0)
+	if err != nil {
+		t.Fatalf("QuerySessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != session.ID || !sessions[0].StartedAt.Equal(testStart) ||
+		sessions[0].EndedAt == nil || !sessions[0].EndedAt.Equal(ended) {
+		t.Errorf("sessions = %+v", sessions)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db := openTestDB(t, dbPath)
+	if err := db.InsertEvents(testEvents()); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+	db.Close()
+
+	readOnly, err := NewReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("NewReadOnly: %v", err)
+	}
+	defer readOnly.Close()
+
+	events, err := readOnly.QueryEvents(QueryFilter{})
+	if err != nil || len(events) != 6 {
+		t.Fatalf("QueryEvents = %d events, %v", len(events), err)
+	}
+	if err := readOnly.InsertEvents(testEvents()[:1]); err == nil {
+		t.Error("read-only store accepted a write")
+	}
+}
+
+// sharedDBEnv names the database TestSharedDatabase hands to the other
+// build.
+const sharedDBEnv = "FSTIMELINE_SHARED_DB"
+
+// TestSharedDatabase writes a database with this build's driver, has the
+// other build read it and add events, and reads them back here.
+func TestSharedDatabase(t *testing.T) {
+	fixture := testEvents()
+
+	if dbPath := os.Getenv(sharedDBEnv); dbPath != "" {
+		db := openTestDB(t, dbPath)
+		checkShared(t, db, fixture[:3])
+		if err := db.InsertEvents(fixture[3:]); err != nil {
+			t.Fatalf("InsertEvents: %v", err)
+		}
+		checkShared(t, db, fixture)
+		return
+	}
+
+	if testing.Short() {
+		t.Skip("builds the package with the other SQLite driver")
+	}
+
+	args := []string{"test", "-count=1", "-run", "^TestSharedDatabase$"}
+	env := os.Environ()
+	if sqliteDriver == "sqlite" {
+		cc, err := exec.Command("go", "env", "CC").Output()
+		if err != nil {
+			t.Skipf("go env CC: %v", err)
+		}
+		if _, err := exec.LookPath(strings.TrimSpace(string(cc))); err != nil {
+			t.Skip("no C compiler for the CGO driver")
+		}
+		env = append(env, "CGO_ENABLED=1")
+	} else {
+		args = append(args, "-tags", "sqlite_purego")
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "shared.db")
+	db := openTestDB(t, dbPath)
+	if err := db.InsertEvents(fixture[:3]); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+	db.Close()
+
+	cmd := exec.Command("go", args...)
+	cmd.Env = append(env, sharedDBEnv+"="+dbPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("other build failed: %v\n%s", err, output)
+	}
+
+	checkShared(t, openTestDB(t, dbPath), fixture)
+
+	readOnly, err := NewReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("NewReadOnly: %v", err)
+	}
+	defer readOnly.Close()
+	checkShared(t, readOnly, fixture)
+}
+
+// checkShared checks that db holds want, in order, and can search them.
+func checkShared(t *testing.T, db *DB, want []*Event) {
+	t.Helper()
+
+	events, err := db.QueryEvents(QueryFilter{Ascending: true})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.FilePath != want[i].FilePath || !event.Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("event %d = %s at %v, want %s at %v", i, event.FilePath, event.Timestamp,
+				want[i].FilePath, want[i].Timestamp)
+		}
+	}
+
+	start := testStart.Add(time.Minute)
+	events, err = db.QueryEvents(QueryFilter{StartTime: &start})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(events) != len(want)-1 {
+		t.Errorf("got %d events since %v, want %d", len(events), start, len(want)-1)
+	}
+
+	last := want[len(want)-1]
+	results, err := db.SearchPaths([]string{last.FileName}, QueryFilter{}, 0)
+	if err != nil {
+		t.Fatalf("SearchPaths: %v", err)
+	}
+	if len(results) == 0 || results[0].FilePath != last.FilePath {
+		t.Errorf("search %q = %+v", last.FileName, results)
+	}
+}
+
+func TestMigrateToUTC(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db := openTestDB(t, dbPath)
+
+	// Older versions stored times with the writer's local offset
+	for _, timestamp := range []string{"2026-03-14 10:30:00+01:00", "2026-03-14 09:45:00+00:00", "2026-03-14 04:50:00-05:00"} {
+		_, err := db.conn.Exec(`INSERT INTO events (timestamp, event_type, file_path, file_name, file_type, directory)
+			VALUES (?, 'WRITE', '/src/a.go', 'a.go', 'go', '/src')`, timestamp)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	_, err := db.conn.Exec(`INSERT INTO sessions (hostname, path, started_at, ended_at)
+		VALUES ('host', '/src', '2026-03-14 10:00:00+01:00', NULL)`)
+	if err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	if _, err := db.conn.Exec(`PRAGMA user_version = 0`); err != nil {
+		t.Fatalf("reset version: %v", err)
+	}
+	db.Close()
+
+	// A read-only open upgrades the database first
+	readOnly, err := NewReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("NewReadOnly: %v", err)
+	}
+	defer readOnly.Close()
+
+	var stored []string
+	rows, err := readOnly.conn.Query(`SELECT CAST(timestamp AS TEXT) FROM events ORDER BY timestamp`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		stored = append(stored, text)
+	}
+	rows.Close()
+	want := []string{"2026-03-14 09:30:00+00:00", "2026-03-14 09:45:00+00:00", "2026-03-14 09:50:00+00:00"}
+	if strings.Join(stored, ",") != strings.Join(want, ",") {
+		t.Errorf("stored timestamps = %v, want %v", stored, want)
+	}
+
+	start := testStart.Add(40 * time.Minute)
+	events, err := readOnly.QueryEvents(QueryFilter{StartTime: &start})
+	if err != nil || len(events) != 2 {
+		t.Errorf("events since 09:40 UTC = %d, %v; want 2", len(events), err)
+	}
+
+	histogram, err := readOnly.Histogram(QueryFilter{}, HistogramOptions{Interval: IntervalHour})
+	if err != nil {
+		t.Fatalf("Histogram: %v", err)
+	}
+	if len(histogram.Buckets) != 1 || histogram.Series[0].Total != 3 {
+		t.Errorf("histogram = %+v, want one bucket of 3", histogram)
+	}
+
+	sessions, err := readOnly.QuerySessions(0)
+	if err != nil || len(sessions) != 1 || !sessions[0].StartedAt.Equal(testStart) {
+		t.Errorf("sessions = %+v, %v", sessions, err)
+	}
+}
+
+func TestInsertEventsSetsIDs(t *testing.T) {
+	db := newTestDB(t)
+
+	events := testEvents()[:2]
+	if err := db.InsertEvents(events); err != nil {
+		t.Fatalf("InsertEvents: %v", err)
+	}
+	if events[0].ID != 7 || events[1].ID != 8 {
+		t.Errorf("ids = %d, %d; want 7, 8", events[0].ID, events[1].ID)
+	}
+
+	// A failed batch leaves the ids alone
+	_, err := db.conn.Exec(`CREATE TRIGGER reject BEFORE INSERT ON events WHEN NEW.file_path = '/b'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	failing := []*Event{testEvent(0, "WRITE", "/a"), testEvent(0, "WRITE", "/b")}
+	if err := db.InsertEvents(failing); err == nil {
+		t.Fatal("InsertEvents accepted a rejected event")
+	}
+	if failing[0].ID != 0 {
+		t.Errorf("id of rolled back event = %d, want 0", failing[0].ID)
+	}
+}
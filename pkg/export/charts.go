@@ -0,0 +1,479 @@
+package export
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// The charts are drawn here as static SVG so the report needs no scripts or
+// assets to show them.
+
+// eventColors match the colors of the interactive chart.
+var eventColors = map[string]string{
+	"CREATE": "#28a745",
+	"WRITE":  "#007bff",
+	"REMOVE": "#dc3545",
+	"RENAME": "#9b59b6",
+	"CHMOD":  "#ffc107",
+}
+
+var fallbackColors = []string{"#17a2b8", "#6c757d", "#fd7e14", "#20c997"}
+
+// heatColors are the calendar's levels of activity, from none to the most.
+var heatColors = []string{"#ebedf0", "#c6cbf5", "#9aa5f0", "#6f7fe0", "#4a5bc9"}
+
+// treemapColors are cycled through the treemap's rectangles.
+var treemapColors = []string{"#667eea", "#764ba2", "#5a67d8", "#9f7aea", "#4c51bf", "#805ad5", "#7f9cf5", "#b794f4"}
+
+// Charts holds the report's charts, drawn as SVG, with the legend and axis
+// labels of the area chart.
+type Charts struct {
+	Calendar template.HTML
+	Area     template.HTML
+	Legend   []LegendItem
+	First    string
+	Last     string
+	Interval database.Interval
+	Treemap  template.HTML
+	TopFiles template.HTML
+}
+
+// LegendItem is one series of the area chart.
+type LegendItem struct {
+	Name  string
+	Color string
+	Total int64
+}
+
+func seriesColor(name string, i int) string {
+	if color, ok := eventColors[name]; ok {
+		return color
+	}
+	return fallbackColors[i%len(fallbackColors)]
+}
+
+// SetCalendar adds a calendar heatmap of daily activity, drawn from a
+// histogram with a day interval.
+func (e *HTMLExporter) SetCalendar(daily *database.Histogram) {
+	e.daily = daily
+}
+
+// SetStats adds a treemap of activity by directory and a chart of the
+// busiest files, drawn from stats with a top N.
+func (e *HTMLExporter) SetStats(stats *database.Stats) {
+	e.stats = stats
+}
+
+// charts draws whichever charts the exporter has data for, or returns nil.
+func (e *HTMLExporter) charts() *Charts {
+	charts := &Charts{}
+	empty := true
+
+	if e.daily != nil && len(e.daily.Buckets) > 0 && len(e.daily.Series) > 0 {
+		charts.Calendar = calendarSVG(e.daily.Buckets, e.daily.Series[0].Counts)
+		empty = false
+	}
+
+	if e.histogram != nil && len(e.histogram.Buckets) > 0 {
+		charts.Area = areaSVG(e.histogram)
+		for i, series := range e.histogram.Series {
+			charts.Legend = append(charts.Legend, LegendItem{Name: series.Name, Color: seriesColor(series.Name, i), Total: series.Total})
+		}
+		charts.First = bucketLabel(e.histogram.Interval, e.histogram.Buckets[0])
+		charts.Last = bucketLabel(e.histogram.Interval, e.histogram.Buckets[len(e.histogram.Buckets)-1])
+		charts.Interval = e.histogram.Interval
+		empty = false
+	}
+
+	if e.stats != nil && len(e.stats.TopDirectories) > 0 {
+		charts.Treemap = treemapSVG(e.stats.TopDirectories)
+		charts.TopFiles = topFilesSVG(e.stats.TopFiles)
+		empty = false
+	}
+
+	if empty {
+		return nil
+	}
+	return charts
+}
+
+func bucketLabel(interval database.Interval, t time.Time) string {
+	if interval == database.IntervalDay || interval == database.IntervalWeek {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+const (
+	cellSize  = 11
+	cellStep  = 13
+	calLeft   = 30
+	calTop    = 15
+	calHeight = calTop + 7*cellStep + 10
+)
+
+// calendarSVG draws one row of weeks per year, Monday at the top, with each
+// day shaded by its share of the busiest day.
+func calendarSVG(days []time.Time, counts []int64) template.HTML {
+	var largest int64
+	for _, count := range counts {
+		largest = max(largest, count)
+	}
+
+	type cell struct {
+		col, row int
+		day      time.Time
+		count    int64
+	}
+	type yearBlock struct {
+		year   int
+		cells  []cell
+		months map[int]string
+		cols   int
+	}
+
+	var years []*yearBlock
+	var block *yearBlock
+	var firstMonday time.Time
+	for i, day := range days {
+		if block == nil || day.Year() != block.year {
+			block = &yearBlock{year: day.Year(), months: make(map[int]string)}
+			years = append(years, block)
+			firstMonday = civil(day).AddDate(0, 0, -weekdayRow(day))
+		}
+
+		col := int(civil(day).Sub(firstMonday).Hours()/24) / 7
+		// Label the month in the column of its first day, and the first
+		// month unless it ends before there is room for its name
+		if day.Day() == 1 || (len(block.cells) == 0 && day.Day() <= 21) {
+			if _, ok := block.months[col]; !ok {
+				block.months[col] = day.Format("Jan")
+			}
+		}
+		block.cells = append(block.cells, cell{col: col, row: weekdayRow(day), day: day, count: counts[i]})
+		block.cols = max(block.cols, col+1)
+	}
+
+	width := 200
+	for _, year := range years {
+		width = max(width, calLeft+year.cols*cellStep)
+	}
+	height := len(years)*calHeight + 20
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="calendar" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	for y, year := range years {
+		top := y * calHeight
+		fmt.Fprintf(&b, `<text x="0" y="%d" class="year">%d</text>`, top+10, year.year)
+		cols := make([]int, 0, len(year.months))
+		for col := range year.months {
+			cols = append(cols, col)
+		}
+		sort.Ints(cols)
+		for _, col := range cols {
+			fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, calLeft+col*cellStep, top+10, year.months[col])
+		}
+		for row, name := range []string{"Mon", "", "Wed", "", "Fri", "", ""} {
+			if name != "" {
+				fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, top+calTop+row*cellStep+9, name)
+			}
+		}
+		for _, c := range year.cells {
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s: %d events</title></rect>`,
+				calLeft+c.col*cellStep, top+calTop+c.row*cellStep, cellSize, cellSize,
+				heatColors[heatLevel(c.count, largest)], c.day.Format("Mon 2006-01-02"), c.count)
+		}
+	}
+
+	// Legend
+	x := width - len(heatColors)*cellStep - 30
+	top := height - 14
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">Less</text>`, x-4, top+9)
+	for i, color := range heatColors {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"/>`, x+i*cellStep, top, cellSize, cellSize, color)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">More</text>`, x+len(heatColors)*cellStep+2, top+9)
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// heatLevel picks a shade for count: 0 for no events, then 1 to 4 by its
+// share of largest.
+func heatLevel(count, largest int64) int {
+	if count <= 0 || largest <= 0 {
+		return 0
+	}
+	return int(min((count*4+largest-1)/largest, 4))
+}
+
+// weekdayRow numbers days from Monday.
+func weekdayRow(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// civil maps a local date to UTC midnight, so days can be counted without
+// daylight saving shifts.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// areaSVG stacks the histogram's series as areas, scaled to the busiest
+// bucket. The legend and axis are left to the page.
+func areaSVG(histogram *database.Histogram) template.HTML {
+	const width, height = 1000.0, 200.0
+
+	n := len(histogram.Buckets)
+	totals := make([]int64, n)
+	var largest int64 = 1
+	for b := range histogram.Buckets {
+		for _, series := range histogram.Series {
+			totals[b] += series.Counts[b]
+		}
+		largest = max(largest, totals[b])
+	}
+
+	x := func(b int) float64 {
+		if n == 1 {
+			return float64(b) * width
+		}
+		return float64(b) * width / float64(n-1)
+	}
+	y := func(value int64) float64 {
+		return height - float64(value)/float64(largest)*height
+	}
+
+	// A single bucket is drawn as a band across the whole chart
+	points := n
+	index := func(p int) int { return p }
+	if n == 1 {
+		points = 2
+		index = func(int) int { return 0 }
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="area" viewBox="0 0 %g %g" preserveAspectRatio="none">`, width, height)
+	lower := make([]int64, n)
+	for i, series := range histogram.Series {
+		var path strings.Builder
+		for p := 0; p < points; p++ {
+			command := "L"
+			if p == 0 {
+				command = "M"
+			}
+			fmt.Fprintf(&path, "%s%.1f,%.1f ", command, x(p), y(lower[index(p)]+series.Counts[index(p)]))
+		}
+		for p := points - 1; p >= 0; p-- {
+			fmt.Fprintf(&path, "L%.1f,%.1f ", x(p), y(lower[index(p)]))
+		}
+		path.WriteString("Z")
+
+		fmt.Fprintf(&b, `<path d="%s" fill="%s" fill-opacity="0.85"><title>%s: %d events</title></path>`,
+			path.String(), seriesColor(series.Name, i), template.HTMLEscapeString(series.Name), series.Total)
+
+		for c := range lower {
+			lower[c] += series.Counts[c]
+		}
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+type box struct {
+	x, y, w, h float64
+}
+
+// treemapSVG lays out the directories as a squarified treemap, labeled
+// relative to the directory their parents share.
+func treemapSVG(dirs []database.Count) template.HTML {
+	const width, height = 560.0, 360.0
+
+	dirs = append([]database.Count(nil), dirs...)
+	sort.SliceStable(dirs, func(i, j int) bool { return dirs[i].Count > dirs[j].Count })
+
+	var total int64
+	keys := make([]string, len(dirs))
+	for i, dir := range dirs {
+		total += dir.Count
+		keys[i] = filepath.Dir(dir.Key)
+	}
+	values := make([]float64, len(dirs))
+	for i, dir := range dirs {
+		values[i] = float64(dir.Count) / float64(total) * width * height
+	}
+	prefix := commonDir(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="treemap" viewBox="0 0 %g %g">`, width, height)
+	for i, rect := range squarify(values, box{0, 0, width, height}) {
+		name := relativeTo(dirs[i].Key, prefix)
+		fmt.Fprintf(&b, `<g><title>%s: %d events</title>`, template.HTMLEscapeString(dirs[i].Key), dirs[i].Count)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="white" stroke-width="2"/>`,
+			rect.x, rect.y, rect.w, rect.h, treemapColors[i%len(treemapColors)])
+		if rect.w > 50 && rect.h > 20 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f">%s</text>`, rect.x+6, rect.y+16,
+				template.HTMLEscapeString(shorten(name, int((rect.w-12)/7))))
+			if rect.h > 38 {
+				fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" class="count">%d</text>`, rect.x+6, rect.y+32, dirs[i].Count)
+			}
+		}
+		b.WriteString(`</g>`)
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// squarify splits area into rectangles for values, largest first, keeping
+// each row's rectangles as close to square as it can. The values must add
+// up to the area.
+func squarify(values []float64, area box) []box {
+	rects := make([]box, 0, len(values))
+
+	for i := 0; i < len(values); {
+		side := min(area.w, area.h)
+		j := i + 1
+		for j < len(values) && worstRatio(values[i:j+1], side) <= worstRatio(values[i:j], side) {
+			j++
+		}
+
+		row := values[i:j]
+		var sum float64
+		for _, value := range row {
+			sum += value
+		}
+
+		if area.w >= area.h {
+			// Fill a column down the left side
+			colWidth := sum / area.h
+			y := area.y
+			for _, value := range row {
+				h := value / colWidth
+				rects = append(rects, box{area.x, y, colWidth, h})
+				y += h
+			}
+			area.x += colWidth
+			area.w -= colWidth
+		} else {
+			// Fill a row along the top
+			rowHeight := sum / area.w
+			x := area.x
+			for _, value := range row {
+				w := value / rowHeight
+				rects = append(rects, box{x, area.y, w, rowHeight})
+				x += w
+			}
+			area.y += rowHeight
+			area.h -= rowHeight
+		}
+		i = j
+	}
+
+	return rects
+}
+
+// worstRatio is the most elongated aspect ratio of row laid along side.
+func worstRatio(row []float64, side float64) float64 {
+	var sum float64
+	smallest, largest := math.Inf(1), 0.0
+	for _, value := range row {
+		sum += value
+		smallest, largest = min(smallest, value), max(largest, value)
+	}
+	if sum == 0 || smallest == 0 {
+		return math.Inf(1)
+	}
+	return max(side*side*largest/(sum*sum), sum*sum/(side*side*smallest))
+}
+
+// topFilesSVG draws a horizontal bar for each of the busiest files.
+func topFilesSVG(files []database.Count) template.HTML {
+	if len(files) == 0 {
+		return ""
+	}
+
+	const width, rowHeight, labelWidth = 600, 24, 250
+	height := len(files) * rowHeight
+
+	keys := make([]string, len(files))
+	largest := files[0].Count
+	for i, file := range files {
+		keys[i] = filepath.Dir(file.Key)
+		largest = max(largest, file.Count)
+	}
+	prefix := commonDir(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="top-files" viewBox="0 0 %d %d">`, width, height)
+	for i, file := range files {
+		y := i * rowHeight
+		bar := float64(file.Count) / float64(largest) * (width - labelWidth - 60)
+		fmt.Fprintf(&b, `<g><title>%s: %d events</title>`, template.HTMLEscapeString(file.Key), file.Count)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-8, y+16,
+			template.HTMLEscapeString(shorten(relativeTo(file.Key, prefix), 36)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" rx="3" fill="#667eea"/>`, labelWidth, y+4, max(bar, 1), rowHeight-8)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="count">%d</text>`, float64(labelWidth)+max(bar, 1)+6, y+16, file.Count)
+		b.WriteString(`</g>`)
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// commonDir returns the deepest directory containing every path, or "".
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+
+	prefix := strings.Split(filepath.ToSlash(paths[0]), "/")
+	for _, path := range paths[1:] {
+		parts := strings.Split(filepath.ToSlash(path), "/")
+		n := 0
+		for n < len(prefix) && n < len(parts) && prefix[n] == parts[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+
+	if dir := strings.Join(prefix, "/"); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// relativeTo strips the directory prefix from path, leaving "." for the
+// directory itself.
+func relativeTo(path, prefix string) string {
+	path = filepath.ToSlash(path)
+	switch {
+	case prefix == "":
+		return path
+	case path == prefix:
+		return "."
+	case prefix == "/":
+		return strings.TrimPrefix(path, "/")
+	}
+	return strings.TrimPrefix(path, prefix+"/")
+}
+
+// shorten replaces the middle of s with an ellipsis to fit width runes.
+func shorten(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width < 2 {
+		return "…"
+	}
+	tail := (width - 1) / 2
+	return string(runes[:width-1-tail]) + "…" + string(runes[len(runes)-tail:])
+}
@@ -0,0 +1,298 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// ecsVersion is the Elastic Common Schema version the documents follow.
+const ecsVersion = "8.11.0"
+
+// ECSDocument is an event as an Elastic Common Schema document.
+type ECSDocument struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Message   string    `json:"message"`
+	ECS       struct {
+		Version string `json:"version"`
+	} `json:"ecs"`
+	Event struct {
+		ID       string   `json:"id,omitempty"`
+		Kind     string   `json:"kind"`
+		Category []string `json:"category"`
+		Type     []string `json:"type"`
+		Action   string   `json:"action"`
+		Provider string   `json:"provider"`
+	} `json:"event"`
+	File ECSFile `json:"file"`
+	Host struct {
+		Name string `json:"name,omitempty"`
+	} `json:"host"`
+}
+
+// ECSFile holds the file.* fields of an ECSDocument.
+type ECSFile struct {
+	Path      string     `json:"path"`
+	Name      string     `json:"name"`
+	Extension string     `json:"extension,omitempty"`
+	Directory string     `json:"directory"`
+	Type      string     `json:"type,omitempty"`
+	Size      *int64     `json:"size,omitempty"`
+	Inode     string     `json:"inode,omitempty"`
+	Mode      string     `json:"mode,omitempty"`
+	UID       string     `json:"uid,omitempty"`
+	GID       string     `json:"gid,omitempty"`
+	Mtime     *time.Time `json:"mtime,omitempty"`
+	Accessed  *time.Time `json:"accessed,omitempty"`
+	Ctime     *time.Time `json:"ctime,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+}
+
+// ecsActions maps event types to ECS event.type and event.action values, as
+// used by file integrity monitoring.
+var ecsActions = map[string][2]string{
+	"CREATE": {"creation", "created"},
+	"WRITE":  {"change", "updated"},
+	"REMOVE": {"deletion", "deleted"},
+	"RENAME": {"change", "moved"},
+	"CHMOD":  {"change", "attributes_modified"},
+}
+
+// NewECSDocument converts an event seen on host to ECS.
+func NewECSDocument(event *database.Event, host string) *ECSDocument {
+	doc := &ECSDocument{
+		Timestamp: event.Timestamp,
+		Message:   event.EventType + " " + event.FilePath,
+		File: ECSFile{
+			Path:      event.FilePath,
+			Name:      event.FileName,
+			Directory: event.Directory,
+			Type:      fileKind(event.Mode),
+			Size:      event.Size,
+			Inode:     intString(event.Inode),
+			UID:       intString(event.UID),
+			GID:       intString(event.GID),
+			Mtime:     event.ModTime,
+			Accessed:  event.AccessTime,
+			Ctime:     event.ChangeTime,
+			Created:   event.BirthTime,
+		},
+	}
+
+	doc.ECS.Version = ecsVersion
+	doc.Event.Kind = "event"
+	doc.Event.Category = []string{"file"}
+	doc.Event.Type = []string{"info"}
+	doc.Event.Action = strings.ToLower(event.EventType)
+	if action, ok := ecsActions[event.EventType]; ok {
+		doc.Event.Type = []string{action[0]}
+		doc.Event.Action = action[1]
+	}
+	doc.Event.Provider = "fstimeline"
+	if event.ID != 0 {
+		doc.Event.ID = strconv.FormatInt(event.ID, 10)
+	}
+	doc.Host.Name = host
+
+	if event.FileType != "no-extension" {
+		doc.File.Extension = event.FileType
+	}
+	if event.Mode != nil {
+		doc.File.Mode = fmt.Sprintf("%04o", *event.Mode&0o7777)
+	}
+
+	return doc
+}
+
+// ecsWriter writes one ECS document per line. For the Elasticsearch bulk API
+// each document is preceded by a create action.
+type ecsWriter struct {
+	out  *bufio.Writer
+	enc  *json.Encoder
+	host string
+	bulk bool
+}
+
+func newECSWriter(out *bufio.Writer, bulk bool) *ecsWriter {
+	return &ecsWriter{out: out, enc: json.NewEncoder(out), host: hostname(), bulk: bulk}
+}
+
+func (w *ecsWriter) Write(event *database.Event) error {
+	if w.bulk {
+		if _, err := w.out.WriteString("{\"create\":{}}\n"); err != nil {
+			return err
+		}
+	}
+	return w.enc.Encode(NewECSDocument(event, w.host))
+}
+
+func (w *ecsWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *ecsWriter) Close() error {
+	return w.out.Flush()
+}
+
+// otlpWriter writes an OTLP/JSON logs export request on a single line, as
+// sent to a collector's /v1/logs endpoint and read by its otlpjsonfile
+// receiver.
+type otlpWriter struct {
+	out   *bufio.Writer
+	host  string
+	count int
+}
+
+// otlpAttribute is a KeyValue of the OTLP/JSON encoding. Integers are
+// encoded as strings.
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string            `json:"timeUnixNano"`
+	ObservedTimeUnixNano string            `json:"observedTimeUnixNano"`
+	SeverityNumber       int               `json:"severityNumber"`
+	SeverityText         string            `json:"severityText"`
+	EventName            string            `json:"eventName"`
+	Body                 map[string]string `json:"body"`
+	Attributes           []otlpAttribute   `json:"attributes"`
+}
+
+// otlpSeverityInfo is the INFO severity number of the OpenTelemetry log data
+// model.
+const otlpSeverityInfo = 9
+
+func (w *otlpWriter) Write(event *database.Event) error {
+	if w.count == 0 {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	} else if err := w.out.WriteByte(','); err != nil {
+		return err
+	}
+	w.count++
+
+	var attrs []otlpAttribute
+	str := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}})
+		}
+	}
+	num := func(key string, value *int64) {
+		if value != nil {
+			attrs = append(attrs, otlpAttribute{Key: key, Value: map[string]string{"intValue": strconv.FormatInt(*value, 10)}})
+		}
+	}
+	timestamp := func(key string, value *time.Time) {
+		if value != nil {
+			str(key, value.Format(time.RFC3339Nano))
+		}
+	}
+
+	str("fstimeline.event_type", event.EventType)
+	str("file.path", event.FilePath)
+	str("file.name", event.FileName)
+	str("file.directory", event.Directory)
+	if event.FileType != "no-extension" {
+		str("file.extension", event.FileType)
+	}
+	num("file.size", event.Size)
+	num("file.inode", event.Inode)
+	if event.Mode != nil {
+		str("file.mode", fmt.Sprintf("%04o", *event.Mode&0o7777))
+	}
+	num("file.owner.id", event.UID)
+	num("file.group.id", event.GID)
+	timestamp("file.modified", event.ModTime)
+	timestamp("file.accessed", event.AccessTime)
+	timestamp("file.changed", event.ChangeTime)
+	timestamp("file.created", event.BirthTime)
+
+	data, err := json.Marshal(otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(event.Timestamp.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(event.Timestamp.UnixNano(), 10),
+		SeverityNumber:       otlpSeverityInfo,
+		SeverityText:         "INFO",
+		EventName:            "file." + strings.ToLower(event.EventType),
+		Body:                 map[string]string{"stringValue": event.EventType + " " + event.FilePath},
+		Attributes:           attrs,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.out.Write(data)
+	return err
+}
+
+// writeHeader opens the request up to the list of log records.
+func (w *otlpWriter) writeHeader() error {
+	resource := []otlpAttribute{{Key: "service.name", Value: map[string]string{"stringValue": "fstimeline"}}}
+	if w.host != "" {
+		resource = append(resource, otlpAttribute{Key: "host.name", Value: map[string]string{"stringValue": w.host}})
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w.out, `{"resourceLogs":[{"resource":{"attributes":%s},"scopeLogs":[{"scope":{"name":"fstimeline"},"logRecords":[`, data)
+	return err
+}
+
+func (w *otlpWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *otlpWriter) Close() error {
+	if w.count == 0 {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.out.WriteString("]}]}]}\n"); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// fileKind returns the ECS file.type of st_mode.
+func fileKind(mode *int64) string {
+	if mode == nil {
+		return ""
+	}
+
+	switch *mode & 0o170000 {
+	case 0o100000:
+		return "file"
+	case 0o040000:
+		return "dir"
+	case 0o120000:
+		return "symlink"
+	}
+	return ""
+}
+
+func intString(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return host
+}
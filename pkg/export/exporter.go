@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// Exporter writes events to a file. ExportStream takes the events from
+// source as they arrive; total is how many there will be.
+type Exporter interface {
+	Export(events []*database.Event, outputPath string) error
+	ExportStream(source EventSource, total int, outputPath string) error
+}
+
+// ExportFormats lists the formats accepted by NewExporter.
+var ExportFormats = []string{"html", "json", "ndjson", "csv", "tsv", "markdown", "bodyfile", "l2tcsv", "ecs", "otlp", "ics"}
+
+// NewExporter returns the exporter for format: the HTML report, a calendar
+// of activity sessions, or a file written with the EventWriter of that
+// format.
+func NewExporter(format string) (Exporter, error) {
+	switch format {
+	case "html":
+		return NewHTMLExporter()
+	case "ics":
+		return NewICSExporter(), nil
+	}
+
+	for _, name := range Formats {
+		if format == name {
+			return &FileExporter{format: format}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+// FormatForPath infers the export format from the extension of path,
+// defaulting to html.
+func FormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".csv":
+		return "csv"
+	case ".tsv":
+		return "tsv"
+	case ".md", ".markdown":
+		return "markdown"
+	case ".body", ".bodyfile":
+		return "bodyfile"
+	case ".ics":
+		return "ics"
+	}
+	return "html"
+}
+
+// FileExporter streams events to a file in one of the EventWriter formats.
+type FileExporter struct {
+	format string
+}
+
+func (e *FileExporter) Export(events []*database.Event, outputPath string) error {
+	return e.ExportStream(sliceSource(events), len(events), outputPath)
+}
+
+func (e *FileExporter) ExportStream(source EventSource, total int, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	out, err := NewEventWriter(e.format, file)
+	if err != nil {
+		return err
+	}
+
+	if err := source(out.Write); err != nil {
+		return fmt.Errorf("failed to export events: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// sliceSource feeds events from memory.
+func sliceSource(events []*database.Event) EventSource {
+	return func(fn func(*database.Event) error) error {
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,235 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// bodyfileWriter writes the Sleuthkit body file format read by mactime:
+//
+//	MD5|name|inode|mode_as_string|UID|GID|size|atime|mtime|ctime|crtime
+//
+// Times are seconds since the epoch. Times that were not captured with the
+// event are filled in from the event itself, according to its type.
+type bodyfileWriter struct {
+	out *bufio.Writer
+}
+
+func (w *bodyfileWriter) Write(event *database.Event) error {
+	atime, mtime, ctime, crtime := event.AccessTime, event.ModTime, event.ChangeTime, event.BirthTime
+	switch event.EventType {
+	case "CREATE":
+		if crtime == nil {
+			crtime = &event.Timestamp
+		}
+	case "WRITE":
+		if mtime == nil {
+			mtime = &event.Timestamp
+		}
+	case "CHMOD", "RENAME", "REMOVE":
+		if ctime == nil {
+			ctime = &event.Timestamp
+		}
+	}
+
+	name := event.FilePath
+	switch event.EventType {
+	case "REMOVE":
+		name += " (deleted)"
+	case "RENAME":
+		name += " (renamed)"
+	}
+
+	_, err := fmt.Fprintf(w.out, "0|%s|%d|%s|%d|%d|%d|%d|%d|%d|%d\n",
+		strings.NewReplacer("|", "\\|", "\n", " ").Replace(name),
+		intOr(event.Inode), modeString(event.Mode), intOr(event.UID), intOr(event.GID), intOr(event.Size),
+		epoch(atime), epoch(mtime), epoch(ctime), epoch(crtime))
+	return err
+}
+
+func (w *bodyfileWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *bodyfileWriter) Close() error {
+	return w.out.Flush()
+}
+
+var l2tHeader = []string{"date", "time", "timezone", "MACB", "source", "sourcetype", "type", "user", "host",
+	"short", "desc", "version", "filename", "inode", "notes", "format", "extra"}
+
+// l2tTimes maps event types to the MACB column and the description of the
+// timestamp in the log2timeline CSV format.
+var l2tTimes = map[string][2]string{
+	"WRITE":  {"M...", "Content Modification Time"},
+	"CREATE": {"...B", "Creation Time"},
+	"CHMOD":  {"..C.", "Metadata Modification Time"},
+	"RENAME": {"..C.", "File Renamed"},
+	"REMOVE": {"..C.", "File Deleted"},
+}
+
+// l2tWriter writes the log2timeline/plaso L2T CSV format, one row per event.
+type l2tWriter struct {
+	out           *csv.Writer
+	headerWritten bool
+}
+
+func (w *l2tWriter) Write(event *database.Event) error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := w.out.Write(l2tHeader); err != nil {
+			return err
+		}
+	}
+
+	macb, description := "....", event.EventType
+	if times, ok := l2tTimes[event.EventType]; ok {
+		macb, description = times[0], times[1]
+	}
+
+	zone, _ := event.Timestamp.Zone()
+
+	return w.out.Write([]string{
+		event.Timestamp.Format("01/02/2006"),
+		event.Timestamp.Format("15:04:05"),
+		zone,
+		macb,
+		"FILE",
+		"File system event",
+		description,
+		intOrDash(event.UID),
+		"-",
+		event.FileName,
+		fmt.Sprintf("%s %s", event.EventType, event.FilePath),
+		"2",
+		event.FilePath,
+		intOrDash(event.Inode),
+		"-",
+		"fstimeline",
+		l2tExtra(event),
+	})
+}
+
+func (w *l2tWriter) Flush() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+func (w *l2tWriter) Close() error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := w.out.Write(l2tHeader); err != nil {
+			return err
+		}
+	}
+	w.out.Flush()
+	return w.out.Error()
+}
+
+// l2tExtra lists the captured metadata of an event as "key: value" pairs.
+func l2tExtra(event *database.Event) string {
+	var extra []string
+	add := func(key, value string) {
+		extra = append(extra, key+": "+value)
+	}
+
+	if event.Size != nil {
+		add("size", strconv.FormatInt(*event.Size, 10))
+	}
+	if event.Mode != nil {
+		add("mode", modeString(event.Mode))
+	}
+	if event.GID != nil {
+		add("gid", strconv.FormatInt(*event.GID, 10))
+	}
+	for _, t := range []struct {
+		key  string
+		time *time.Time
+	}{
+		{"mtime", event.ModTime},
+		{"atime", event.AccessTime},
+		{"ctime", event.ChangeTime},
+		{"crtime", event.BirthTime},
+	} {
+		if t.time != nil {
+			add(t.key, t.time.Format(time.RFC3339))
+		}
+	}
+
+	if len(extra) == 0 {
+		return "-"
+	}
+	return strings.Join(extra, "; ")
+}
+
+// modeString formats st_mode the way Sleuthkit does, as the type of the
+// name followed by the metadata type and permissions, e.g. "r/rrw-r--r--".
+func modeString(mode *int64) string {
+	if mode == nil {
+		return "-/----------"
+	}
+
+	m := *mode
+	kind := map[int64]byte{
+		0o010000: 'p',
+		0o020000: 'c',
+		0o040000: 'd',
+		0o060000: 'b',
+		0o100000: 'r',
+		0o120000: 'l',
+		0o140000: 's',
+	}[m&0o170000]
+	if kind == 0 {
+		kind = '-'
+	}
+
+	perm := []byte("rwxrwxrwx")
+	for i := range perm {
+		if m&(1<<(8-i)) == 0 {
+			perm[i] = '-'
+		}
+	}
+	special := func(bit int64, i int, set, unset byte) {
+		if m&bit == 0 {
+			return
+		}
+		if perm[i] == 'x' {
+			perm[i] = set
+		} else {
+			perm[i] = unset
+		}
+	}
+	special(0o4000, 2, 's', 'S')
+	special(0o2000, 5, 's', 'S')
+	special(0o1000, 8, 't', 'T')
+
+	return string([]byte{kind, '/', kind}) + string(perm)
+}
+
+// epoch returns t in seconds since the epoch, or 0 if it is unknown.
+func epoch(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func intOr(value *int64) int64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func intOrDash(value *int64) string {
+	if value == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*value, 10)
+}
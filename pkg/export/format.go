@@ -0,0 +1,299 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// EventWriter writes events one at a time. Flush pushes buffered events to
+// the underlying writer. Close finishes the output and flushes it; it does
+// not close the underlying writer.
+type EventWriter interface {
+	Write(event *database.Event) error
+	Flush() error
+	Close() error
+}
+
+// Formats lists the machine-readable formats accepted by NewEventWriter,
+// besides "template=<text/template>".
+var Formats = []string{"json", "ndjson", "csv", "tsv", "markdown", "bodyfile", "l2tcsv", "ecs", "otlp"}
+
+// NewEventWriter returns a streaming writer for format.
+func NewEventWriter(format string, w io.Writer) (EventWriter, error) {
+	if text, ok := strings.CutPrefix(format, "template="); ok {
+		return newTemplateWriter(text, w)
+	}
+
+	switch format {
+	case "json":
+		return &jsonWriter{out: bufio.NewWriter(w)}, nil
+	case "ndjson":
+		out := bufio.NewWriter(w)
+		return &ndjsonWriter{out: out, enc: json.NewEncoder(out)}, nil
+	case "csv":
+		return &csvWriter{out: csv.NewWriter(w)}, nil
+	case "tsv":
+		out := csv.NewWriter(w)
+		out.Comma = '\t'
+		return &csvWriter{out: out}, nil
+	case "markdown":
+		return &markdownWriter{out: bufio.NewWriter(w)}, nil
+	case "bodyfile":
+		return &bodyfileWriter{out: bufio.NewWriter(w)}, nil
+	case "l2tcsv":
+		return &l2tWriter{out: csv.NewWriter(w)}, nil
+	case "ecs":
+		return newECSWriter(bufio.NewWriter(w), false), nil
+	case "otlp":
+		return &otlpWriter{out: bufio.NewWriter(w), host: hostname()}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q (use %s or template=...)", format, strings.Join(Formats, ", "))
+}
+
+// EventRecord is the JSON representation of an event.
+type EventRecord struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	EventType string `json:"event_type"`
+	FilePath  string `json:"file_path"`
+	FileName  string `json:"file_name"`
+	FileType  string `json:"file_type"`
+	Directory string `json:"directory"`
+	Size      *int64 `json:"size"`
+}
+
+func NewEventRecord(event *database.Event) EventRecord {
+	return EventRecord{
+		ID:        event.ID,
+		Timestamp: event.Timestamp.Format(time.RFC3339Nano),
+		EventType: event.EventType,
+		FilePath:  event.FilePath,
+		FileName:  event.FileName,
+		FileType:  event.FileType,
+		Directory: event.Directory,
+		Size:      event.Size,
+	}
+}
+
+// jsonWriter streams a single JSON array.
+type jsonWriter struct {
+	out   *bufio.Writer
+	count int
+}
+
+func (w *jsonWriter) Write(event *database.Event) error {
+	data, err := json.Marshal(NewEventRecord(event))
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n  "
+	if w.count == 0 {
+		sep = "[\n  "
+	}
+	w.count++
+
+	if _, err := w.out.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = w.out.Write(data)
+	return err
+}
+
+func (w *jsonWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *jsonWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := w.out.WriteString(end); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+type ndjsonWriter struct {
+	out *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(event *database.Event) error {
+	return w.enc.Encode(NewEventRecord(event))
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.out.Flush()
+}
+
+var csvHeader = []string{"id", "timestamp", "event_type", "file_path", "file_name", "file_type", "directory", "size"}
+
+type csvWriter struct {
+	out           *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(event *database.Event) error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := w.out.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	size := ""
+	if event.Size != nil {
+		size = strconv.FormatInt(*event.Size, 10)
+	}
+
+	return w.out.Write([]string{
+		strconv.FormatInt(event.ID, 10),
+		event.Timestamp.Format(time.RFC3339Nano),
+		event.EventType,
+		event.FilePath,
+		event.FileName,
+		event.FileType,
+		event.Directory,
+		size,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+func (w *csvWriter) Close() error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := w.out.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.out.Flush()
+	return w.out.Error()
+}
+
+// markdownWriter writes a table of events under a heading for each date.
+type markdownWriter struct {
+	out         *bufio.Writer
+	currentDate string
+	count       int
+}
+
+func (w *markdownWriter) Write(event *database.Event) error {
+	if w.count == 0 {
+		w.out.WriteString("# File System Timeline\n")
+	}
+	w.count++
+
+	date := event.Timestamp.Format("2006-01-02")
+	if date != w.currentDate {
+		w.currentDate = date
+		fmt.Fprintf(w.out, "\n## %s\n\n| Time | Event | Path | Type | Size |\n|------|-------|------|------|-----:|\n", date)
+	}
+
+	size := ""
+	if event.Size != nil {
+		size = strconv.FormatInt(*event.Size, 10)
+	}
+
+	_, err := fmt.Fprintf(w.out, "| %s | %s | %s | %s | %s |\n", event.Timestamp.Format("15:04:05"),
+		event.EventType, markdownCell(event.FilePath), markdownCell(event.FileType), size)
+	return err
+}
+
+func (w *markdownWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *markdownWriter) Close() error {
+	if w.count == 0 {
+		w.out.WriteString("# File System Timeline\n\nNo events found.\n")
+	} else {
+		fmt.Fprintf(w.out, "\nTotal events: %d\n", w.count)
+	}
+	return w.out.Flush()
+}
+
+// markdownCell escapes text for a table cell, in code so that paths are
+// shown as they are.
+func markdownCell(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.NewReplacer("|", "\\|", "\n", " ", "\r", " ").Replace(text)
+	if strings.Contains(text, "`") {
+		return "`` " + text + " ``"
+	}
+	return "`" + text + "`"
+}
+
+// templateWriter executes a text/template once per event, with the
+// *database.Event as data. A newline is added unless the template ends
+// with one.
+type templateWriter struct {
+	out     *bufio.Writer
+	tmpl    *template.Template
+	newline bool
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"size": func(size *int64) string {
+		if size == nil {
+			return ""
+		}
+		return strconv.FormatInt(*size, 10)
+	},
+}
+
+func newTemplateWriter(text string, w io.Writer) (*templateWriter, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse format template: %w", err)
+	}
+
+	return &templateWriter{
+		out:     bufio.NewWriter(w),
+		tmpl:    tmpl,
+		newline: !strings.HasSuffix(text, "\n"),
+	}, nil
+}
+
+func (w *templateWriter) Write(event *database.Event) error {
+	if err := w.tmpl.Execute(w.out, event); err != nil {
+		return err
+	}
+	if w.newline {
+		return w.out.WriteByte('\n')
+	}
+	return nil
+}
+
+func (w *templateWriter) Flush() error {
+	return w.out.Flush()
+}
+
+func (w *templateWriter) Close() error {
+	return w.out.Flush()
+}
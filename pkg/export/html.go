@@ -1,38 +1,82 @@
 package export
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"os"
-	"time"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
 )
 
-const htmlTemplate = `<!DOCTYPE html>
+// htmlTemplate is split around the embedded event data, which is written one
+// event at a time; the page renders and filters the events itself.
+const htmlTemplate = `{{define "header"}}<!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>File System Timeline</title>
+    <title>{{.Title}}</title>
     <style>
+        body {
+            --page: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            --banner: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            --banner-text: white;
+            --surface: white;
+            --panel: #f8f9fa;
+            --chip: #e9ecef;
+            --chip-text: #495057;
+            --text: #333;
+            --muted: #666;
+            --accent: #667eea;
+            --line: #dee2e6;
+            --row-line: #eee;
+            --shadow: 0 10px 40px rgba(0,0,0,0.2);
+        }
+        body.theme-dark {
+            --page: #12131a;
+            --banner: linear-gradient(135deg, #3c4a9e 0%, #4d2f6e 100%);
+            --surface: #1e1f29;
+            --panel: #272836;
+            --chip: #33354a;
+            --chip-text: #c8cbe0;
+            --text: #e4e6f0;
+            --muted: #9a9db3;
+            --accent: #8c9eff;
+            --line: #3a3c50;
+            --row-line: #2c2e3d;
+            --shadow: 0 10px 40px rgba(0,0,0,0.5);
+        }
+        body.theme-print {
+            --page: white;
+            --banner: white;
+            --banner-text: #222;
+            --panel: white;
+            --accent: #333;
+            --shadow: none;
+            padding: 0;
+        }
+        body.theme-print .header {
+            border-bottom: 2px solid #333;
+        }
         body {
             font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            background: var(--page);
             margin: 0;
             padding: 20px;
         }
         .container {
             max-width: 1200px;
             margin: 0 auto;
-            background: white;
+            background: var(--surface);
             border-radius: 10px;
-            box-shadow: 0 10px 40px rgba(0,0,0,0.2);
+            box-shadow: var(--shadow);
             overflow: hidden;
         }
         .header {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
+            background: var(--banner);
+            color: var(--banner-text);
             padding: 30px;
             text-align: center;
         }
@@ -40,122 +84,784 @@ const htmlTemplate = `<!DOCTYPE html>
             margin: 0;
             font-size: 2.5em;
         }
+        .header .logo {
+            max-height: 60px;
+            max-width: 240px;
+            margin-bottom: 15px;
+        }
         .header p {
             margin: 10px 0 0 0;
             opacity: 0.9;
         }
-        .timeline {
-            padding: 30px;
+        .controls {
+            padding: 30px 30px 0 30px;
+        }
+        .controls input[type=search] {
+            width: 100%;
+            box-sizing: border-box;
+            padding: 10px 12px;
+            font-size: 1em;
+            border: 1px solid var(--line);
+            border-radius: 5px;
+            background: var(--surface);
+            color: var(--text);
+        }
+        .toggles {
+            margin-top: 12px;
+        }
+        .toggles span, .dirs span {
+            display: inline-block;
+            cursor: pointer;
+            user-select: none;
+            padding: 4px 10px;
+            margin: 0 6px 6px 0;
+            border-radius: 4px;
+            background: var(--chip);
+            color: var(--chip-text);
+            font-size: 0.9em;
         }
-        .date-group {
-            margin-bottom: 30px;
+        .toggles span.off {
+            opacity: 0.35;
+            text-decoration: line-through;
         }
-        .date-header {
-            font-size: 1.3em;
-            color: #667eea;
+        .crumbs {
+            margin-top: 12px;
             font-weight: bold;
-            margin-bottom: 15px;
-            padding-bottom: 10px;
-            border-bottom: 2px solid #667eea;
+            color: var(--accent);
         }
-        .event {
-            display: flex;
-            align-items: center;
-            padding: 15px;
-            margin: 10px 0;
-            background: #f8f9fa;
-            border-left: 4px solid #ddd;
-            border-radius: 5px;
-            transition: all 0.3s;
+        .crumbs a {
+            cursor: pointer;
+            color: var(--accent);
+        }
+        .dirs {
+            margin-top: 8px;
+        }
+        .dirs span:hover {
+            background: var(--line);
+        }
+        .status {
+            margin-top: 6px;
+            color: var(--muted);
+            font-size: 0.9em;
         }
-        .event:hover {
-            transform: translateX(5px);
-            box-shadow: 0 4px 8px rgba(0,0,0,0.1);
+        .status a {
+            cursor: pointer;
+            color: var(--accent);
+            margin-left: 10px;
+        }
+        .timeline {
+            padding: 20px 30px 30px 30px;
+        }
+        .events {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        .events th {
+            text-align: left;
+            cursor: pointer;
+            user-select: none;
+            color: var(--accent);
+            padding: 10px;
+            border-bottom: 2px solid var(--accent);
+            white-space: nowrap;
+        }
+        .events td {
+            padding: 8px 10px;
+            border-bottom: 1px solid var(--row-line);
+            vertical-align: top;
+        }
+        .events tr:hover td {
+            background: var(--panel);
         }
         .event-time {
             font-weight: bold;
-            color: #666;
-            min-width: 80px;
+            color: var(--muted);
+            white-space: nowrap;
         }
         .event-type {
-            padding: 5px 10px;
+            display: inline-block;
+            padding: 3px 8px;
             border-radius: 4px;
             font-weight: bold;
-            min-width: 80px;
+            min-width: 60px;
             text-align: center;
-            margin: 0 15px;
         }
-        .event-type-CREATE { background: #d4edda; color: #155724; border-left-color: #28a745; }
-        .event-type-WRITE { background: #cce5ff; color: #004085; border-left-color: #007bff; }
-        .event-type-REMOVE { background: #f8d7da; color: #721c24; border-left-color: #dc3545; }
-        .event-type-RENAME { background: #e2d5f0; color: #5a2d7a; border-left-color: #9b59b6; }
-        .event-type-CHMOD { background: #fff3cd; color: #856404; border-left-color: #ffc107; }
+        .event-type-CREATE { background: #d4edda; color: #155724; }
+        .event-type-WRITE { background: #cce5ff; color: #004085; }
+        .event-type-REMOVE { background: #f8d7da; color: #721c24; }
+        .event-type-RENAME { background: #e2d5f0; color: #5a2d7a; }
+        .event-type-CHMOD { background: #fff3cd; color: #856404; }
         .event-path {
-            flex: 1;
-            color: #333;
+            color: var(--text);
             word-break: break-all;
         }
-        .event-filetype {
-            background: #e9ecef;
-            padding: 5px 10px;
+        .event-filetype, .event-size {
+            color: var(--chip-text);
+            white-space: nowrap;
+        }
+        .event-size {
+            text-align: right;
+        }
+        .more {
+            display: block;
+            margin: 20px auto 0 auto;
+            padding: 8px 20px;
+            border: 1px solid var(--accent);
+            border-radius: 5px;
+            background: var(--surface);
+            color: var(--accent);
+            cursor: pointer;
+        }
+        .chart {
+            padding: 30px 30px 0 30px;
+            position: relative;
+        }
+        .chart svg {
+            width: 100%;
+            height: 200px;
+            display: block;
+            border-bottom: 1px solid var(--line);
+            cursor: crosshair;
+        }
+        .chart-legend {
+            margin-bottom: 10px;
+        }
+        .chart-legend span {
+            cursor: pointer;
+            margin-right: 15px;
+            user-select: none;
+        }
+        .chart-legend span.off {
+            opacity: 0.35;
+        }
+        .chart-legend i {
+            display: inline-block;
+            width: 12px;
+            height: 12px;
+            margin-right: 5px;
+            border-radius: 2px;
+        }
+        .chart-axis {
+            display: flex;
+            justify-content: space-between;
+            color: var(--muted);
+            font-size: 0.85em;
+            margin-top: 5px;
+        }
+        .chart-tooltip {
+            position: absolute;
+            display: none;
+            pointer-events: none;
+            background: rgba(0,0,0,0.8);
+            color: white;
+            padding: 8px 10px;
             border-radius: 4px;
-            font-size: 0.9em;
-            color: #495057;
+            font-size: 0.85em;
+            white-space: nowrap;
+        }
+        .charts {
+            display: grid;
+            grid-template-columns: 1fr 1fr;
+            gap: 20px;
+            padding: 30px 30px 0 30px;
+        }
+        .card {
+            background: var(--panel);
+            border-radius: 5px;
+            padding: 15px;
+            min-width: 0;
+        }
+        .card.wide {
+            grid-column: 1 / -1;
+        }
+        .card h2 {
+            margin: 0 0 10px 0;
+            font-size: 1.1em;
+            color: var(--accent);
+        }
+        .card svg {
+            display: block;
+            width: 100%;
+            height: auto;
+        }
+        .card svg text {
+            font-size: 10px;
+            fill: var(--muted);
+        }
+        .card .chart-legend {
+            margin: 10px 0 0 0;
+        }
+        .card .chart-legend span {
+            cursor: default;
+        }
+        .calendar-scroll {
+            overflow-x: auto;
+        }
+        .card svg.calendar {
+            width: auto;
+        }
+        .card svg.area {
+            height: 150px;
+        }
+        .card .treemap text {
+            font-size: 12px;
+            fill: white;
+        }
+        .card .treemap text.count {
+            fill-opacity: 0.8;
+        }
+        .card .top-files text {
+            font-size: 12px;
+            fill: var(--text);
+        }
+        @media (max-width: 800px) {
+            .charts {
+                grid-template-columns: 1fr;
+            }
         }
         .footer {
-            background: #f8f9fa;
+            background: var(--panel);
             padding: 20px;
             text-align: center;
-            color: #666;
-            border-top: 1px solid #dee2e6;
+            color: var(--muted);
+            border-top: 1px solid var(--line);
+        }
+        @media print {
+            body {
+                --page: white;
+                --shadow: none;
+                padding: 0;
+            }
+            .controls, .more, .chart-tooltip, noscript {
+                display: none !important;
+            }
+            .card, .events tr {
+                break-inside: avoid;
+            }
         }
     </style>
 </head>
-<body>
+<body class="theme-{{.Theme}}">
     <div class="container">
         <div class="header">
-            <h1>📂 File System Timeline</h1>
-            <p>Generated on {{.GeneratedAt}}</p>
+            {{- if .Logo}}
+            <img class="logo" src="{{.Logo}}" alt="">
+            <h1>{{.Title}}</h1>
+            {{- else}}
+            <h1>📂 {{.Title}}</h1>
+            {{- end}}
+            <p>Generated on {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</p>
             <p>Total Events: {{.TotalEvents}}</p>
         </div>
-        <div class="timeline">
-            {{range $date, $events := .EventsByDate}}
-            <div class="date-group">
-                <div class="date-header">📅 {{$date}}</div>
-                {{range $events}}
-                <div class="event">
-                    <div class="event-time">{{.TimeStr}}</div>
-                    <div class="event-type event-type-{{.EventType}}">{{.EventType}}</div>
-                    <div class="event-path">{{.FilePath}}</div>
-                    <div class="event-filetype">{{.FileType}}</div>
-                </div>
-                {{end}}
+{{if .Histogram}}
+        <div class="chart">
+            <div class="chart-legend" id="chart-legend"></div>
+            <svg id="chart" viewBox="0 0 1000 200" preserveAspectRatio="none"></svg>
+            <div class="chart-axis"><span id="chart-first"></span><span>events per {{.Histogram.Interval}} &middot; drag to select a time range, double-click to clear</span><span id="chart-last"></span></div>
+            <div class="chart-tooltip" id="chart-tooltip"></div>
+        </div>
+        <script type="application/json" id="histogram-data">{{.Histogram}}</script>
+        <script>
+        (function () {
+            var data = JSON.parse(document.getElementById('histogram-data').textContent);
+            var colors = {CREATE: '#28a745', WRITE: '#007bff', REMOVE: '#dc3545', RENAME: '#9b59b6', CHMOD: '#ffc107'};
+            var fallback = ['#17a2b8', '#6c757d', '#fd7e14', '#20c997'];
+            var svg = document.getElementById('chart');
+            var legend = document.getElementById('chart-legend');
+            var tooltip = document.getElementById('chart-tooltip');
+            var hidden = {};
+            var ns = 'http://www.w3.org/2000/svg';
+            var brush = null;
+            var dragFrom = null;
+
+            function color(name, i) {
+                return colors[name] || fallback[i % fallback.length];
+            }
+
+            // Buckets carry the exporting machine's offset, so their text is
+            // already in its local time
+            function label(bucket) {
+                if (data.interval === 'day' || data.interval === 'week') {
+                    return bucket.slice(0, 10);
+                }
+                return bucket.slice(0, 16).replace('T', ' ');
+            }
+
+            function showTooltip(event, b) {
+                var lines = [label(data.buckets[b])];
+                data.series.forEach(function (series) {
+                    if (!hidden[series.name]) {
+                        lines.push(series.name + ': ' + series.counts[b]);
+                    }
+                });
+                tooltip.textContent = '';
+                lines.forEach(function (line, i) {
+                    if (i > 0) {
+                        tooltip.appendChild(document.createElement('br'));
+                    }
+                    tooltip.appendChild(document.createTextNode(line));
+                });
+                var box = svg.parentNode.getBoundingClientRect();
+                tooltip.style.left = (event.clientX - box.left + 12) + 'px';
+                tooltip.style.top = (event.clientY - box.top + 12) + 'px';
+                tooltip.style.display = 'block';
+            }
+
+            function draw() {
+                while (svg.firstChild) {
+                    svg.removeChild(svg.firstChild);
+                }
+
+                var largest = 1;
+                data.buckets.forEach(function (_, b) {
+                    var total = 0;
+                    data.series.forEach(function (series) {
+                        if (!hidden[series.name]) {
+                            total += series.counts[b];
+                        }
+                    });
+                    largest = Math.max(largest, total);
+                });
+
+                var width = 1000 / data.buckets.length;
+                data.buckets.forEach(function (_, b) {
+                    var y = 200;
+                    data.series.forEach(function (series, i) {
+                        if (hidden[series.name] || !series.counts[b]) {
+                            return;
+                        }
+                        var height = series.counts[b] / largest * 200;
+                        y -= height;
+                        var rect = document.createElementNS(ns, 'rect');
+                        rect.setAttribute('x', b * width);
+                        rect.setAttribute('y', y);
+                        rect.setAttribute('width', Math.max(width * 0.85, 0.5));
+                        rect.setAttribute('height', height);
+                        rect.setAttribute('fill', color(series.name, i));
+                        svg.appendChild(rect);
+                    });
+
+                    var hit = document.createElementNS(ns, 'rect');
+                    hit.setAttribute('x', b * width);
+                    hit.setAttribute('y', 0);
+                    hit.setAttribute('width', width);
+                    hit.setAttribute('height', 200);
+                    hit.setAttribute('fill', 'transparent');
+                    hit.addEventListener('mousemove', function (event) {
+                        showTooltip(event, b);
+                    });
+                    hit.addEventListener('mouseleave', function () {
+                        tooltip.style.display = 'none';
+                    });
+                    svg.appendChild(hit);
+                });
+
+                if (brush) {
+                    var area = document.createElementNS(ns, 'rect');
+                    area.setAttribute('x', brush[0] * width);
+                    area.setAttribute('y', 0);
+                    area.setAttribute('width', (brush[1] - brush[0] + 1) * width);
+                    area.setAttribute('height', 200);
+                    area.setAttribute('fill', 'rgba(102,126,234,0.2)');
+                    area.setAttribute('pointer-events', 'none');
+                    svg.appendChild(area);
+                }
+            }
+
+            function bucketAt(event) {
+                var box = svg.getBoundingClientRect();
+                var b = Math.floor((event.clientX - box.left) / box.width * data.buckets.length);
+                return Math.min(Math.max(b, 0), data.buckets.length - 1);
+            }
+
+            // The report listens for the selected range of bucket start times
+            function publish() {
+                var range = null;
+                if (brush) {
+                    var next = brush[1] + 1;
+                    range = {
+                        start: Date.parse(data.buckets[brush[0]]),
+                        end: next < data.buckets.length ? Date.parse(data.buckets[next]) : Infinity
+                    };
+                }
+                document.dispatchEvent(new CustomEvent('timerange', {detail: range}));
+            }
+
+            svg.addEventListener('mousedown', function (event) {
+                dragFrom = bucketAt(event);
+                brush = [dragFrom, dragFrom];
+                event.preventDefault();
+                draw();
+            });
+            svg.addEventListener('mousemove', function (event) {
+                if (dragFrom !== null) {
+                    var b = bucketAt(event);
+                    brush = [Math.min(dragFrom, b), Math.max(dragFrom, b)];
+                    draw();
+                }
+            });
+            document.addEventListener('mouseup', function () {
+                if (dragFrom !== null) {
+                    dragFrom = null;
+                    publish();
+                }
+            });
+            svg.addEventListener('dblclick', function () {
+                brush = null;
+                draw();
+                publish();
+            });
+            document.addEventListener('timerange-reset', function () {
+                brush = null;
+                draw();
+            });
+
+            data.series.forEach(function (series, i) {
+                var item = document.createElement('span');
+                var swatch = document.createElement('i');
+                swatch.style.background = color(series.name, i);
+                item.appendChild(swatch);
+                item.appendChild(document.createTextNode(series.name + ' (' + series.total + ')'));
+                item.addEventListener('click', function () {
+                    hidden[series.name] = !hidden[series.name];
+                    item.className = hidden[series.name] ? 'off' : '';
+                    draw();
+                });
+                legend.appendChild(item);
+            });
+
+            document.getElementById('chart-first').textContent = label(data.buckets[0]);
+            document.getElementById('chart-last').textContent = label(data.buckets[data.buckets.length - 1]);
+            draw();
+        })();
+        </script>
+{{end}}
+{{with .Charts}}
+        <div class="charts">
+{{- if .Calendar}}
+            <div class="card wide">
+                <h2>Daily activity</h2>
+                <div class="calendar-scroll">{{.Calendar}}</div>
+            </div>
+{{- end}}
+{{- if .Area}}
+            <div class="card wide">
+                <h2>Events by type</h2>
+                {{.Area}}
+                <div class="chart-axis"><span>{{.First}}</span><span>events per {{.Interval}}</span><span>{{.Last}}</span></div>
+                <div class="chart-legend">{{range .Legend}}<span><i style="background: {{.Color}}"></i>{{.Name}} ({{.Total}})</span>{{end}}</div>
             </div>
-            {{end}}
+{{- end}}
+{{- if .Treemap}}
+            <div class="card">
+                <h2>Activity by directory</h2>
+                {{.Treemap}}
+            </div>
+{{- end}}
+{{- if .TopFiles}}
+            <div class="card">
+                <h2>Busiest files</h2>
+                {{.TopFiles}}
+            </div>
+{{- end}}
+        </div>
+{{end}}
+        <div class="controls">
+            <input type="search" id="search" placeholder="Search paths" autocomplete="off">
+            <div class="toggles" id="event-toggles"></div>
+            <div class="toggles" id="type-toggles"></div>
+            <div class="crumbs" id="crumbs"></div>
+            <div class="dirs" id="dirs"></div>
+            <div class="status"><span id="status"></span><a id="reset">Reset filters</a></div>
+        </div>
+        <div class="timeline">
+            <noscript>Browsing the events needs JavaScript.</noscript>
+            <table class="events">
+                <thead>
+                    <tr><th data-key="time">Time</th><th data-key="event">Event</th><th data-key="path">Path</th><th data-key="type">Type</th><th data-key="size">Size</th></tr>
+                </thead>
+                <tbody id="rows"></tbody>
+            </table>
+            <button class="more" id="more">Show more</button>
         </div>
+{{end}}{{define "footer"}}
+        <script>
+        (function () {
+            // Each row is [time, event type, path, directory, file type, size]
+            var rows = JSON.parse(document.getElementById('events-data').textContent);
+            var separator = new RegExp('[\\\\/]');
+            // Printed reports list every event
+            var pageSize = document.body.className === 'theme-print' ? Infinity : 500;
+
+            var events = rows.map(function (row, i) {
+                return {
+                    index: i,
+                    time: row[0],
+                    ms: Date.parse(row[0]),
+                    event: row[1],
+                    path: row[2],
+                    lower: row[2].toLowerCase(),
+                    dirs: row[3].split(separator).filter(function (part) { return part !== ''; }),
+                    type: row[4] || '(none)',
+                    size: row[5]
+                };
+            });
+
+            // Directories are browsed from the deepest one holding every event,
+            // usually the watched directory
+            var base = events.length ? events[0].dirs.slice() : [];
+            events.forEach(function (e) {
+                var n = 0;
+                while (n < base.length && n < e.dirs.length && base[n] === e.dirs[n]) {
+                    n++;
+                }
+                base.length = n;
+            });
+            var absolute = rows.length > 0 && rows[0][3].charAt(0) === '/';
+            var baseLabel = (absolute ? '/' : '') + base.join('/') || 'All directories';
+
+            var state;
+            function initial() {
+                return {terms: [], hiddenEvents: {}, hiddenTypes: {}, cwd: [], range: null, sortKey: 'time', sortDir: 1, shown: pageSize};
+            }
+            state = initial();
+
+            var search = document.getElementById('search');
+            var tbody = document.getElementById('rows');
+            var more = document.getElementById('more');
+            var status = document.getElementById('status');
+            var crumbs = document.getElementById('crumbs');
+            var dirs = document.getElementById('dirs');
+            var headers = document.querySelectorAll('.events th');
+
+            function matches(e) {
+                if (state.range && (e.ms < state.range.start || e.ms >= state.range.end)) {
+                    return false;
+                }
+                if (state.hiddenEvents[e.event] || state.hiddenTypes[e.type]) {
+                    return false;
+                }
+                for (var i = 0; i < state.terms.length; i++) {
+                    if (e.lower.indexOf(state.terms[i]) < 0) {
+                        return false;
+                    }
+                }
+                for (var j = 0; j < state.cwd.length; j++) {
+                    if (e.dirs[base.length + j] !== state.cwd[j]) {
+                        return false;
+                    }
+                }
+                return true;
+            }
+
+            function compare(a, b) {
+                var x, y;
+                switch (state.sortKey) {
+                case 'time':
+                    x = a.ms; y = b.ms;
+                    break;
+                case 'size':
+                    x = a.size === null ? -1 : a.size;
+                    y = b.size === null ? -1 : b.size;
+                    break;
+                default:
+                    x = a[state.sortKey]; y = b[state.sortKey];
+                }
+                if (x < y) {
+                    return -state.sortDir;
+                }
+                if (x > y) {
+                    return state.sortDir;
+                }
+                return a.index - b.index;
+            }
+
+            function formatSize(size) {
+                if (size === null) {
+                    return '';
+                }
+                var units = ['B', 'KB', 'MB', 'GB', 'TB'];
+                var unit = 0;
+                var value = size;
+                while (value >= 1024 && unit < units.length - 1) {
+                    value /= 1024;
+                    unit++;
+                }
+                return unit === 0 ? size + ' B' : value.toFixed(1) + ' ' + units[unit];
+            }
+
+            function cell(row, className, text) {
+                var td = document.createElement('td');
+                td.className = className;
+                td.textContent = text;
+                row.appendChild(td);
+                return td;
+            }
+
+            function renderRows(filtered) {
+                tbody.textContent = '';
+                filtered.slice(0, state.shown).forEach(function (e) {
+                    var row = document.createElement('tr');
+                    cell(row, 'event-time', e.time.slice(0, 10) + ' ' + e.time.slice(11, 19));
+                    var badge = document.createElement('span');
+                    badge.className = 'event-type event-type-' + e.event;
+                    badge.textContent = e.event;
+                    cell(row, '', '').appendChild(badge);
+                    cell(row, 'event-path', e.path);
+                    cell(row, 'event-filetype', e.type);
+                    cell(row, 'event-size', formatSize(e.size));
+                    tbody.appendChild(row);
+                });
+                more.style.display = filtered.length > state.shown ? 'block' : 'none';
+            }
+
+            function renderDirs(filtered) {
+                crumbs.textContent = '';
+                var path = [baseLabel].concat(state.cwd);
+                path.forEach(function (name, depth) {
+                    if (depth > 0) {
+                        crumbs.appendChild(document.createTextNode(' / '));
+                    }
+                    if (depth === path.length - 1) {
+                        crumbs.appendChild(document.createTextNode(name));
+                        return;
+                    }
+                    var link = document.createElement('a');
+                    link.textContent = name;
+                    link.addEventListener('click', function () {
+                        state.cwd = state.cwd.slice(0, depth);
+                        update();
+                    });
+                    crumbs.appendChild(link);
+                });
+
+                var counts = {};
+                var depth = base.length + state.cwd.length;
+                filtered.forEach(function (e) {
+                    var name = e.dirs[depth];
+                    if (name !== undefined) {
+                        counts[name] = (counts[name] || 0) + 1;
+                    }
+                });
+
+                dirs.textContent = '';
+                Object.keys(counts).sort(function (a, b) {
+                    return counts[b] - counts[a] || (a < b ? -1 : 1);
+                }).slice(0, 50).forEach(function (name) {
+                    var chip = document.createElement('span');
+                    chip.textContent = name + '/ (' + counts[name] + ')';
+                    chip.addEventListener('click', function () {
+                        state.cwd = state.cwd.concat([name]);
+                        update();
+                    });
+                    dirs.appendChild(chip);
+                });
+            }
+
+            function update() {
+                var filtered = events.filter(matches);
+                filtered.sort(compare);
+
+                renderRows(filtered);
+                renderDirs(filtered);
+                status.textContent = 'Showing ' + Math.min(filtered.length, state.shown) + ' of ' + filtered.length +
+                    ' matching events (' + events.length + ' in the report)';
+
+                headers.forEach(function (th) {
+                    var key = th.getAttribute('data-key');
+                    th.textContent = th.getAttribute('data-label') +
+                        (key === state.sortKey ? (state.sortDir > 0 ? ' ▲' : ' ▼') : '');
+                });
+            }
+
+            function toggles(container, key, hidden) {
+                var counts = {};
+                events.forEach(function (e) {
+                    counts[e[key]] = (counts[e[key]] || 0) + 1;
+                });
+                Object.keys(counts).sort(function (a, b) {
+                    return counts[b] - counts[a] || (a < b ? -1 : 1);
+                }).forEach(function (name) {
+                    var chip = document.createElement('span');
+                    chip.textContent = name + ' (' + counts[name] + ')';
+                    chip.addEventListener('click', function () {
+                        state[hidden][name] = !state[hidden][name];
+                        chip.className = state[hidden][name] ? 'off' : '';
+                        update();
+                    });
+                    container.appendChild(chip);
+                });
+            }
+
+            toggles(document.getElementById('event-toggles'), 'event', 'hiddenEvents');
+            toggles(document.getElementById('type-toggles'), 'type', 'hiddenTypes');
+
+            headers.forEach(function (th) {
+                th.setAttribute('data-label', th.textContent);
+                th.addEventListener('click', function () {
+                    var key = th.getAttribute('data-key');
+                    state.sortDir = key === state.sortKey ? -state.sortDir : 1;
+                    state.sortKey = key;
+                    update();
+                });
+            });
+
+            var pending;
+            search.addEventListener('input', function () {
+                clearTimeout(pending);
+                pending = setTimeout(function () {
+                    state.terms = search.value.toLowerCase().split(' ').filter(function (term) { return term !== ''; });
+                    state.shown = pageSize;
+                    update();
+                }, 150);
+            });
+
+            more.addEventListener('click', function () {
+                state.shown += pageSize;
+                update();
+            });
+
+            document.addEventListener('timerange', function (event) {
+                state.range = event.detail;
+                state.shown = pageSize;
+                update();
+            });
+
+            document.getElementById('reset').addEventListener('click', function () {
+                state = initial();
+                search.value = '';
+                document.querySelectorAll('.toggles span').forEach(function (chip) {
+                    chip.className = '';
+                });
+                document.dispatchEvent(new CustomEvent('timerange-reset'));
+                update();
+            });
+
+            update();
+        })();
+        </script>
         <div class="footer">
             <p>File System Timeline Monitor - github.com/BaseMax/go-fs-timeline</p>
         </div>
     </div>
 </body>
-</html>`
+</html>
+{{end}}`
 
 type HTMLExporter struct {
-	tmpl *template.Template
-}
-
-type eventData struct {
-	TimeStr   string
-	EventType string
-	FilePath  string
-	FileType  string
+	tmpl      *template.Template
+	custom    *template.Template
+	histogram *database.Histogram
+	daily     *database.Histogram
+	stats     *database.Stats
+	title     string
+	logo      template.URL
+	theme     string
 }
 
-type templateData struct {
-	GeneratedAt  string
-	TotalEvents  int
-	EventsByDate map[string][]eventData
-}
+// EventSource feeds events to fn in timeline order, stopping at the first
+// error fn returns.
+type EventSource func(fn func(*database.Event) error) error
 
 func NewHTMLExporter() (*HTMLExporter, error) {
 	tmpl, err := template.New("timeline").Parse(htmlTemplate)
@@ -163,28 +869,24 @@ func NewHTMLExporter() (*HTMLExporter, error) {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	return &HTMLExporter{tmpl: tmpl}, nil
+	return &HTMLExporter{tmpl: tmpl, title: "File System Timeline", theme: "light"}, nil
+}
+
+// SetHistogram adds an activity chart above the timeline.
+func (e *HTMLExporter) SetHistogram(histogram *database.Histogram) {
+	e.histogram = histogram
 }
 
 func (e *HTMLExporter) Export(events []*database.Event, outputPath string) error {
-	// Group events by date
-	eventsByDate := make(map[string][]eventData)
-	for _, event := range events {
-		dateStr := event.Timestamp.Format("2006-01-02")
-		timeStr := event.Timestamp.Format("15:04:05")
-
-		eventsByDate[dateStr] = append(eventsByDate[dateStr], eventData{
-			TimeStr:   timeStr,
-			EventType: event.EventType,
-			FilePath:  event.FilePath,
-			FileType:  event.FileType,
-		})
-	}
+	return e.ExportStream(sliceSource(events), len(events), outputPath)
+}
 
-	data := templateData{
-		GeneratedAt:  time.Now().Format("2006-01-02 15:04:05"),
-		TotalEvents:  len(events),
-		EventsByDate: eventsByDate,
+// ExportStream writes events from source as they arrive, so exports of any
+// size need only constant memory. total is shown in the header. A custom
+// template is given all the events at once instead.
+func (e *HTMLExporter) ExportStream(source EventSource, total int, outputPath string) error {
+	if e.custom != nil {
+		return e.exportCustom(source, total, outputPath)
 	}
 
 	file, err := os.Create(outputPath)
@@ -193,9 +895,54 @@ func (e *HTMLExporter) Export(events []*database.Event, outputPath string) error
 	}
 	defer file.Close()
 
-	if err := e.tmpl.Execute(file, data); err != nil {
+	out := bufio.NewWriter(file)
+
+	data := e.reportData(total)
+	if err := e.tmpl.ExecuteTemplate(out, "header", data); err != nil {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
 
+	out.WriteString(`        <script type="application/json" id="events-data">[`)
+	count := 0
+	err = source(func(event *database.Event) error {
+		row, err := eventRow(event)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			out.WriteByte(',')
+		}
+		count++
+		out.WriteString("\n")
+		_, err = out.Write(row)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export events: %w", err)
+	}
+	out.WriteString("]</script>\n")
+
+	if err := e.tmpl.ExecuteTemplate(out, "footer", data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
 	return nil
 }
+
+// eventRow encodes an event as the page expects it: time, event type, path,
+// directory, file type and size. JSON escapes "<", so a path cannot end the
+// script element it is embedded in.
+func eventRow(event *database.Event) ([]byte, error) {
+	return json.Marshal([]any{
+		event.Timestamp.Format("2006-01-02T15:04:05.000Z07:00"),
+		event.EventType,
+		event.FilePath,
+		event.Directory,
+		event.FileType,
+		event.Size,
+	})
+}
@@ -0,0 +1,291 @@
+package export
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// DefaultIdleGap is how long a project can go without events before its
+// activity session ends.
+const DefaultIdleGap = 30 * time.Minute
+
+// minSessionLength is the length given to sessions of a single burst of
+// events, so that calendars show them.
+const minSessionLength = time.Minute
+
+// ICSExporter writes activity sessions as iCalendar events: for each project
+// directory, runs of events no more than the idle gap apart.
+type ICSExporter struct {
+	title string
+	idle  time.Duration
+	depth int
+	root  string
+}
+
+// activity is a session of events in one directory or project.
+type activity struct {
+	Start, End time.Time
+	Events     int
+	Types      map[string]int
+}
+
+func NewICSExporter() *ICSExporter {
+	return &ICSExporter{
+		title: "File System Timeline",
+		idle:  DefaultIdleGap,
+		depth: 1,
+	}
+}
+
+// SetTitle names the calendar.
+func (e *ICSExporter) SetTitle(title string) {
+	e.title = title
+}
+
+// SetIdleGap sets how long a project can go without events before its
+// session ends.
+func (e *ICSExporter) SetIdleGap(idle time.Duration) error {
+	if idle <= 0 {
+		return fmt.Errorf("idle gap must be positive, got %s", idle)
+	}
+	e.idle = idle
+	return nil
+}
+
+// SetProjectDepth sets how many directory levels below the project root make
+// up a project.
+func (e *ICSExporter) SetProjectDepth(depth int) error {
+	if depth < 1 {
+		return fmt.Errorf("project depth must be at least 1, got %d", depth)
+	}
+	e.depth = depth
+	return nil
+}
+
+// SetProjectRoot sets the directory holding the projects. By default it is
+// the deepest directory common to all events.
+func (e *ICSExporter) SetProjectRoot(root string) {
+	e.root = strings.TrimSuffix(filepath.ToSlash(root), "/")
+}
+
+func (e *ICSExporter) Export(events []*database.Event, outputPath string) error {
+	return e.ExportStream(sliceSource(events), len(events), outputPath)
+}
+
+// ExportStream reads events oldest first. Sessions are tracked per directory
+// while reading and merged into projects at the end, which gives the same
+// sessions as tracking each project's events directly.
+func (e *ICSExporter) ExportStream(source EventSource, total int, outputPath string) error {
+	dirs := make(map[string][]*activity)
+
+	err := source(func(event *database.Event) error {
+		sessions := dirs[event.Directory]
+		if len(sessions) == 0 || event.Timestamp.Sub(sessions[len(sessions)-1].End) > e.idle {
+			sessions = append(sessions, &activity{Start: event.Timestamp, Types: make(map[string]int)})
+			dirs[event.Directory] = sessions
+		}
+		session := sessions[len(sessions)-1]
+		session.End = event.Timestamp
+		session.Events++
+		session.Types[event.EventType]++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export events: %w", err)
+	}
+
+	root := e.root
+	if root == "" {
+		root = commonDir(sortedKeys(dirs))
+	}
+
+	projects := make(map[string][]*activity)
+	for dir, sessions := range dirs {
+		project := e.project(root, dir)
+		projects[project] = append(projects[project], sessions...)
+	}
+
+	type projectSession struct {
+		project string
+		*activity
+	}
+	var sessions []projectSession
+	for project, list := range projects {
+		for _, session := range e.merge(list) {
+			sessions = append(sessions, projectSession{project, session})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Start.Equal(sessions[j].Start) {
+			return sessions[i].Start.Before(sessions[j].Start)
+		}
+		return sessions[i].project < sessions[j].project
+	})
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	out := &icsWriter{out: bufio.NewWriter(file)}
+	out.line("BEGIN:VCALENDAR")
+	out.line("VERSION:2.0")
+	out.line("PRODID:-//fstimeline//Activity Sessions//EN")
+	out.line("CALSCALE:GREGORIAN")
+	out.line("METHOD:PUBLISH")
+	out.property("X-WR-CALNAME", e.title)
+
+	stamp := icsTime(time.Now())
+	for _, session := range sessions {
+		end := session.End
+		if end.Sub(session.Start) < minSessionLength {
+			end = session.Start.Add(minSessionLength)
+		}
+
+		name := relativeTo(session.project, root)
+		if name == "." {
+			name = session.project
+		}
+
+		out.line("BEGIN:VEVENT")
+		out.line("UID:" + sessionUID(session.project, session.Start))
+		out.line("DTSTAMP:" + stamp)
+		out.line("DTSTART:" + icsTime(session.Start))
+		out.line("DTEND:" + icsTime(end))
+		out.property("SUMMARY", name)
+		out.property("DESCRIPTION", fmt.Sprintf("%d events in %s (%s)", session.Events, session.project, typeCounts(session.Types)))
+		out.line("CATEGORIES:fstimeline")
+		out.line("TRANSP:OPAQUE")
+		out.line("END:VEVENT")
+	}
+	out.line("END:VCALENDAR")
+
+	if err := out.out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// project returns the project directory of dir: the first levels of it below
+// root. Directories outside root are projects of their own.
+func (e *ICSExporter) project(root, dir string) string {
+	dir = filepath.ToSlash(dir)
+	rel := relativeTo(dir, root)
+	switch {
+	case rel == ".":
+		return dir
+	case root != "" && rel == dir:
+		return dir
+	}
+
+	lead := ""
+	if strings.HasPrefix(rel, "/") {
+		lead, rel = "/", rel[1:]
+	}
+	parts := strings.Split(rel, "/")
+	if len(parts) > e.depth {
+		parts = parts[:e.depth]
+	}
+
+	return path.Join(root, lead+strings.Join(parts, "/"))
+}
+
+// merge joins the sessions of a project's directories that overlap or are
+// within the idle gap of each other.
+func (e *ICSExporter) merge(sessions []*activity) []*activity {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+
+	var merged []*activity
+	for _, session := range sessions {
+		if len(merged) > 0 {
+			last := merged[len(merged)-1]
+			if session.Start.Sub(last.End) <= e.idle {
+				if session.End.After(last.End) {
+					last.End = session.End
+				}
+				last.Events += session.Events
+				for eventType, count := range session.Types {
+					last.Types[eventType] += count
+				}
+				continue
+			}
+		}
+		merged = append(merged, session)
+	}
+
+	return merged
+}
+
+// sessionUID identifies a session by its project and start, so that
+// re-exported sessions replace their earlier versions in calendars.
+func sessionUID(project string, start time.Time) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d", project, start.UnixNano())))
+	return fmt.Sprintf("%x@fstimeline", sum[:12])
+}
+
+// typeCounts lists event counts by type, most frequent first.
+func typeCounts(types map[string]int) string {
+	names := sortedKeys(types)
+	slices.SortStableFunc(names, func(a, b string) int {
+		return types[b] - types[a]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", types[name], name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsWriter writes content lines, folded at 75 octets as RFC 5545 requires.
+type icsWriter struct {
+	out *bufio.Writer
+}
+
+func (w *icsWriter) line(text string) {
+	limit := 75
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		w.out.WriteString(text[:cut] + "\r\n ")
+		text = text[cut:]
+		limit = 74 // after the leading space
+	}
+	w.out.WriteString(text + "\r\n")
+}
+
+// property writes a text property, escaped.
+func (w *icsWriter) property(name, value string) {
+	value = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+	w.line(name + ":" + value)
+}
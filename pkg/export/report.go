@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// Themes lists the report themes accepted by SetTheme.
+var Themes = []string{"light", "dark", "print"}
+
+// ReportData is what report templates are executed with. Days is only filled
+// in for custom templates; the built-in template streams the events instead.
+type ReportData struct {
+	Title       string
+	Logo        template.URL // data: URL of the logo image, if any
+	Theme       string
+	GeneratedAt time.Time
+	TotalEvents int
+	Stats       *database.Stats
+	Histogram   *database.Histogram
+	Charts      *Charts
+	Days        []ReportDay
+}
+
+// ReportDay holds the events of one local date, oldest first.
+type ReportDay struct {
+	Date   string
+	Events []*database.Event
+}
+
+// SetTitle replaces the report's title.
+func (e *HTMLExporter) SetTitle(title string) {
+	e.title = title
+}
+
+// SetTheme picks one of Themes.
+func (e *HTMLExporter) SetTheme(theme string) error {
+	for _, name := range Themes {
+		if theme == name {
+			e.theme = theme
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (use %s)", theme, strings.Join(Themes, ", "))
+}
+
+// SetLogo embeds the image at path in the report header.
+func (e *HTMLExporter) SetLogo(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read logo: %w", err)
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	if !strings.HasPrefix(mimeType, "image/") {
+		return fmt.Errorf("logo %s is not an image", path)
+	}
+
+	e.logo = template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// SetTemplate renders the report with the html/template file at path instead
+// of the built-in one. It is executed with a ReportData and has the same
+// helper functions as the template output format.
+func (e *HTMLExporter) SetTemplate(path string) error {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(template.FuncMap(templateFuncs)).Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	e.custom = tmpl
+	return nil
+}
+
+func (e *HTMLExporter) reportData(total int) ReportData {
+	return ReportData{
+		Title:       e.title,
+		Logo:        e.logo,
+		Theme:       e.theme,
+		GeneratedAt: time.Now(),
+		TotalEvents: total,
+		Stats:       e.stats,
+		Histogram:   e.histogram,
+		Charts:      e.charts(),
+	}
+}
+
+// exportCustom loads the events from source, grouped by date, and executes
+// the custom template with them.
+func (e *HTMLExporter) exportCustom(source EventSource, total int, outputPath string) error {
+	data := e.reportData(total)
+
+	err := source(func(event *database.Event) error {
+		date := event.Timestamp.Format("2006-01-02")
+		if len(data.Days) == 0 || data.Days[len(data.Days)-1].Date != date {
+			data.Days = append(data.Days, ReportDay{Date: date})
+		}
+		day := &data.Days[len(data.Days)-1]
+		day.Events = append(day.Events, event)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export events: %w", err)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	out := bufio.NewWriter(file)
+	if err := e.custom.Execute(out, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// sinkTimeout bounds each request to a collector.
+const sinkTimeout = 10 * time.Second
+
+// Sink forwards batches of events as they are recorded, either to a file or
+// to an HTTP endpoint.
+type Sink struct {
+	format  string
+	target  string
+	url     string
+	headers http.Header
+	client  *http.Client
+	file    *os.File
+}
+
+// appendFormats are the formats whose batches can follow each other in a
+// file: one record or request per line, with no header or enclosing document.
+var appendFormats = []string{"ndjson", "ecs", "otlp", "bodyfile"}
+
+// NewSink returns a sink writing format to target. An http:// or https://
+// target is posted to: ecs goes to the Elasticsearch bulk API of the index or
+// data stream in the URL, otlp to an OTLP/HTTP logs endpoint such as
+// http://localhost:4318/v1/logs, and other formats as the request body.
+// Any other target is a file that batches are appended to, which only
+// appendFormats allow.
+func NewSink(format, target string, headers http.Header) (*Sink, error) {
+	if _, err := NewEventWriter(format, io.Discard); err != nil {
+		return nil, err
+	}
+
+	sink := &Sink{format: format, target: target, headers: headers}
+
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sink URL: %w", err)
+		}
+		sink.target = parsed.Redacted()
+		sink.url = target
+		if format == "ecs" {
+			bulk := parsed.JoinPath("_bulk")
+			params := bulk.Query()
+			params.Set("filter_path", "errors,items.*.error")
+			bulk.RawQuery = params.Encode()
+			sink.url = bulk.String()
+		}
+		sink.client = &http.Client{Timeout: sinkTimeout}
+		return sink, nil
+	}
+
+	if !slices.Contains(appendFormats, format) {
+		return nil, fmt.Errorf("%s cannot be appended to a file sink (use %s)", format, strings.Join(appendFormats, ", "))
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink file: %w", err)
+	}
+	sink.file = file
+
+	return sink, nil
+}
+
+// ParseSink parses a "format=target" sink specification.
+func ParseSink(spec string, headers http.Header) (*Sink, error) {
+	format, target, ok := strings.Cut(spec, "=")
+	if !ok || format == "" || target == "" {
+		return nil, fmt.Errorf("invalid sink %q (use format=url or format=file)", spec)
+	}
+	return NewSink(format, target, headers)
+}
+
+func (s *Sink) String() string {
+	return s.format + " sink " + s.target
+}
+
+// Send forwards events.
+func (s *Sink) Send(events []*database.Event) error {
+	if s.file != nil {
+		return s.writeAll(s.file, events, false)
+	}
+
+	var body bytes.Buffer
+	if err := s.writeAll(&body, events, s.format == "ecs"); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range s.headers {
+		req.Header[key] = values
+	}
+	switch s.format {
+	case "ecs", "ndjson":
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		req.Header.Set("Content-Type", "text/csv")
+	case "markdown":
+		req.Header.Set("Content-Type", "text/markdown")
+	default:
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+
+	if s.format == "ecs" {
+		return bulkErrors(reply)
+	}
+
+	return nil
+}
+
+// writeAll writes events to w in the sink's format.
+func (s *Sink) writeAll(w io.Writer, events []*database.Event, bulk bool) error {
+	var out EventWriter
+	if bulk {
+		out = newECSWriter(bufio.NewWriter(w), true)
+	} else {
+		var err error
+		if out, err = NewEventWriter(s.format, w); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range events {
+		if err := out.Write(event); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// bulkErrors reports the first document Elasticsearch rejected, as the bulk
+// API answers 200 even when some fail.
+func bulkErrors(reply []byte) error {
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error any `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(reply, &result); err != nil || !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first any
+	for _, item := range result.Items {
+		for _, op := range item {
+			if op.Error != nil {
+				if failed == 0 {
+					first = op.Error
+				}
+				failed++
+			}
+		}
+	}
+
+	return fmt.Errorf("%d documents rejected: %v", failed, first)
+}
+
+// Close closes the sink's file, if any.
+func (s *Sink) Close() error {
+	if s.file != nil {
+		return s.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package history reconstructs the lifecycle of a single file from its
+// events, following it across renames.
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// Step is one entry in a file's history. Consecutive writes are coalesced
+// into a single step spanning Start to End.
+type Step struct {
+	Path      string
+	EventType string
+	Start     time.Time
+	End       time.Time
+	Count     int
+	// Size is the last size recorded during the step, if any.
+	Size *int64
+	// RenamedFrom and RenamedTo link a CREATE or RENAME to the other side of
+	// a move.
+	RenamedFrom string
+	RenamedTo   string
+}
+
+// maxHops bounds how many renames Trace follows in each direction.
+const maxHops = 32
+
+// Trace returns the history of path, oldest first. The watcher records a
+// move as a RENAME of the old path and a CREATE of the new one, so a RENAME
+// and a CREATE of another path within window are treated as the same file.
+// The history is followed back through the rename that created path and
+// forward through the rename that ended it.
+func Trace(db database.Store, path string, window time.Duration) ([]*Step, error) {
+	steps, err := pathSteps(db, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	if err := linkRenames(db, steps, window); err != nil {
+		return nil, err
+	}
+
+	visited := map[string]bool{path: true}
+
+	// Follow the file back to where it was moved from
+	for hops := 0; hops < maxHops; hops++ {
+		first := steps[0]
+		if first.RenamedFrom == "" || visited[first.RenamedFrom] {
+			break
+		}
+		visited[first.RenamedFrom] = true
+
+		before := first.Start
+		earlier, err := pathSteps(db, first.RenamedFrom, nil, &before)
+		if err != nil {
+			return nil, err
+		}
+		if len(earlier) == 0 {
+			break
+		}
+		if err := linkRenames(db, earlier, window); err != nil {
+			return nil, err
+		}
+		steps = append(earlier, steps...)
+	}
+
+	// Follow the file forward to where it was moved to
+	for hops := 0; hops < maxHops; hops++ {
+		last := steps[len(steps)-1]
+		if last.RenamedTo == "" || visited[last.RenamedTo] {
+			break
+		}
+		visited[last.RenamedTo] = true
+
+		after := last.Start
+		later, err := pathSteps(db, last.RenamedTo, &after, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(later) == 0 {
+			break
+		}
+		if err := linkRenames(db, later, window); err != nil {
+			return nil, err
+		}
+		steps = append(steps, later...)
+	}
+
+	return steps, nil
+}
+
+// pathSteps loads the events of one path between start and end, coalescing
+// runs of writes.
+func pathSteps(db database.Store, path string, start, end *time.Time) ([]*Step, error) {
+	filter := database.QueryFilter{
+		FilePath:  path,
+		StartTime: start,
+		EndTime:   end,
+		Ascending: true,
+	}
+
+	var steps []*Step
+	err := db.IterateEvents(filter, func(event *database.Event) error {
+		if len(steps) > 0 {
+			last := steps[len(steps)-1]
+			if event.EventType == "WRITE" && last.EventType == "WRITE" {
+				last.End = event.Timestamp
+				last.Count++
+				if event.Size != nil {
+					last.Size = event.Size
+				}
+				return nil
+			}
+		}
+
+		steps = append(steps, &Step{
+			Path:      event.FilePath,
+			EventType: event.EventType,
+			Start:     event.Timestamp,
+			End:       event.Timestamp,
+			Count:     1,
+			Size:      event.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+
+	return steps, nil
+}
+
+// linkRenames fills in RenamedFrom for each CREATE that closely follows a
+// RENAME of another path, and RenamedTo for each RENAME closely followed by
+// a CREATE of another path.
+func linkRenames(db database.Store, steps []*Step, window time.Duration) error {
+	for _, step := range steps {
+		var err error
+		switch step.EventType {
+		case "CREATE":
+			start := step.Start.Add(-window)
+			step.RenamedFrom, err = counterpart(db, step, "RENAME", start, step.Start)
+		case "RENAME":
+			end := step.Start.Add(window)
+			step.RenamedTo, err = counterpart(db, step, "CREATE", step.Start, end)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// counterpart finds the path of the eventType event between start and end
+// most likely to be the other side of a move involving step: one with the
+// same file name if there is one, otherwise the closest in time.
+func counterpart(db database.Store, step *Step, eventType string, start, end time.Time) (string, error) {
+	events, err := db.QueryEvents(database.QueryFilter{
+		StartTime:  &start,
+		EndTime:    &end,
+		EventTypes: []string{eventType},
+		Ascending:  true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to query renames: %w", err)
+	}
+
+	var best *database.Event
+	for _, event := range events {
+		if event.FilePath == step.Path {
+			continue
+		}
+		if best == nil || better(event, best, step) {
+			best = event
+		}
+	}
+
+	if best == nil {
+		return "", nil
+	}
+	return best.FilePath, nil
+}
+
+func better(event, best *database.Event, step *Step) bool {
+	name := filepath.Base(step.Path)
+	if (event.FileName == name) != (best.FileName == name) {
+		return event.FileName == name
+	}
+	return distance(event.Timestamp, step.Start) < distance(best.Timestamp, step.Start)
+}
+
+func distance(a, b time.Time) time.Duration {
+	if a.After(b) {
+		return a.Sub(b)
+	}
+	return b.Sub(a)
+}
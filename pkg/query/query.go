@@ -0,0 +1,424 @@
+// Package query parses the timeline expression language, for example
+//
+//	type:go AND event:WRITE AND path:~"internal/" AND time > -2h AND size > 1MB
+//
+// into an AST. The database package translates the AST into SQL.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Node interface {
+	// Span returns the byte offsets of the node in the input.
+	Span() (start, end int)
+}
+
+type And struct {
+	Left, Right Node
+}
+
+type Or struct {
+	Left, Right Node
+}
+
+type Not struct {
+	Expr Node
+	Pos  int
+}
+
+type Op string
+
+const (
+	OpMatch Op = ":"
+	OpRegex Op = ":~"
+	OpEq    Op = "="
+	OpNe    Op = "!="
+	OpLt    Op = "<"
+	OpLe    Op = "<="
+	OpGt    Op = ">"
+	OpGe    Op = ">="
+)
+
+// Comparison tests one field against a value. Time and Size hold the parsed
+// value for the time and size fields.
+type Comparison struct {
+	Field string
+	Op    Op
+	Value string
+	Time  time.Time
+	Size  int64
+
+	Pos, End int
+}
+
+func (n *And) Span() (int, int) {
+	start, _ := n.Left.Span()
+	_, end := n.Right.Span()
+	return start, end
+}
+
+func (n *Or) Span() (int, int) {
+	start, _ := n.Left.Span()
+	_, end := n.Right.Span()
+	return start, end
+}
+
+func (n *Not) Span() (int, int) {
+	_, end := n.Expr.Span()
+	return n.Pos, end
+}
+
+func (n *Comparison) Span() (int, int) {
+	return n.Pos, n.End
+}
+
+// Fields lists the fields of the language with the operators each accepts.
+var Fields = map[string][]Op{
+	"type":  {OpMatch, OpRegex, OpEq, OpNe},
+	"event": {OpMatch, OpRegex, OpEq, OpNe},
+	"path":  {OpMatch, OpRegex, OpEq, OpNe},
+	"name":  {OpMatch, OpRegex, OpEq, OpNe},
+	"dir":   {OpMatch, OpRegex, OpEq, OpNe},
+	"time":  {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+	"size":  {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+}
+
+var fieldAliases = map[string]string{
+	"ext":       "type",
+	"directory": "dir",
+	"file":      "name",
+}
+
+// Error points at the part of the input that could not be understood.
+type Error struct {
+	Input    string
+	Pos, End int
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	end := e.End
+	if end <= e.Pos {
+		end = e.Pos + 1
+	}
+
+	return fmt.Sprintf("%s at column %d\n  %s\n  %s%s", e.Msg, e.Pos+1, e.Input,
+		strings.Repeat(" ", e.Pos), strings.Repeat("^", end-e.Pos))
+}
+
+// Query is a parsed expression.
+type Query struct {
+	Input string
+	Root  Node
+}
+
+type parser struct {
+	input     string
+	pos       int
+	parseTime func(string) (time.Time, error)
+}
+
+// Parse parses input. parseTime converts the values of the time field, so
+// the language accepts whatever time formats the caller supports.
+func Parse(input string, parseTime func(string) (time.Time, error)) (*Query, error) {
+	p := &parser{input: input, parseTime: parseTime}
+
+	p.skipSpace()
+	if p.pos == len(p.input) {
+		return nil, p.errorf(0, 0, "empty expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		start, end := p.peekWord()
+		return nil, p.errorf(start, end, "unexpected %q", p.input[start:end])
+	}
+
+	return &Query{Input: input, Root: root}, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseAnd also accepts terms separated only by whitespace.
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos == len(p.input) || p.input[p.pos] == ')' || p.peekKeyword("OR") {
+			return left, nil
+		}
+		p.keyword("AND")
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.skipSpace()
+	start := p.pos
+
+	if p.keyword("NOT") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: expr, Pos: start}, nil
+	}
+
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.input) || p.input[p.pos] != ')' {
+			return nil, p.errorf(start, start+1, "unclosed parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	p.skipSpace()
+	start := p.pos
+
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || p.input[p.pos] == '_') {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.input) {
+			return nil, p.errorf(p.pos, p.pos, "expected a condition such as type:go")
+		}
+		wordStart, wordEnd := p.peekWord()
+		return nil, p.errorf(wordStart, wordEnd, "expected a field name, found %q", p.input[wordStart:wordEnd])
+	}
+
+	field := strings.ToLower(p.input[start:p.pos])
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	ops, ok := Fields[field]
+	if !ok {
+		return nil, p.errorf(start, p.pos, "unknown field %q (expected type, event, path, name, dir, time or size)", p.input[start:p.pos])
+	}
+	fieldEnd := p.pos
+
+	p.skipSpace()
+	opStart := p.pos
+	op := p.parseOp()
+	if op == "" {
+		return nil, p.errorf(fieldEnd, fieldEnd, "expected an operator after %q", field)
+	}
+	if !hasOp(ops, op) {
+		return nil, p.errorf(opStart, p.pos, "operator %q cannot be used with %s (use %s)", op, field, joinOps(ops))
+	}
+
+	p.skipSpace()
+	valueStart := p.pos
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Comparison{Field: field, Op: op, Value: value, Pos: start, End: p.pos}
+
+	switch {
+	case field == "event" && op != OpRegex:
+		node.Value = strings.ToUpper(value)
+
+	case field == "time":
+		if node.Time, err = p.parseTime(value); err != nil {
+			return nil, p.errorf(valueStart, p.pos, "invalid time %q: %v", value, err)
+		}
+
+	case field == "size":
+		if node.Size, err = ParseSize(value); err != nil {
+			return nil, p.errorf(valueStart, p.pos, "%v", err)
+		}
+	}
+
+	if op == OpRegex {
+		if _, err := regexp.Compile(value); err != nil {
+			return nil, p.errorf(valueStart, p.pos, "invalid regular expression: %v", err)
+		}
+	}
+
+	return node, nil
+}
+
+func (p *parser) parseOp() Op {
+	for _, op := range []Op{OpRegex, OpNe, OpLe, OpGe, OpMatch, OpEq, OpLt, OpGt} {
+		if strings.HasPrefix(p.input[p.pos:], string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseValue reads a double-quoted string or a bare word ending at
+// whitespace or a closing parenthesis.
+func (p *parser) parseValue() (string, error) {
+	start := p.pos
+	if p.pos == len(p.input) {
+		return "", p.errorf(p.pos, p.pos, "expected a value")
+	}
+
+	if p.input[p.pos] == '"' {
+		p.pos++
+		var builder strings.Builder
+		for p.pos < len(p.input) {
+			c := p.input[p.pos]
+			switch {
+			case c == '\\' && p.pos+1 < len(p.input):
+				builder.WriteByte(p.input[p.pos+1])
+				p.pos += 2
+			case c == '"':
+				p.pos++
+				return builder.String(), nil
+			default:
+				builder.WriteByte(c)
+				p.pos++
+			}
+		}
+		return "", p.errorf(start, p.pos, "unterminated string")
+	}
+
+	for p.pos < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos])) && p.input[p.pos] != ')' {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf(p.pos, p.pos, "expected a value")
+	}
+
+	return p.input[start:p.pos], nil
+}
+
+// keyword consumes word if it is next in the input, ignoring case.
+func (p *parser) keyword(word string) bool {
+	if !p.peekKeyword(word) {
+		return false
+	}
+	p.skipSpace()
+	p.pos += len(word)
+	return true
+}
+
+func (p *parser) peekKeyword(word string) bool {
+	p.skipSpace()
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	return end == len(p.input) || unicode.IsSpace(rune(p.input[end])) || p.input[end] == '('
+}
+
+func (p *parser) peekWord() (int, int) {
+	end := p.pos
+	for end < len(p.input) && !unicode.IsSpace(rune(p.input[end])) {
+		end++
+	}
+	return p.pos, end
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(start, end int, format string, args ...interface{}) error {
+	return &Error{Input: p.input, Pos: start, End: end, Msg: fmt.Sprintf(format, args...)}
+}
+
+func hasOp(ops []Op, op Op) bool {
+	for _, candidate := range ops {
+		if candidate == op {
+			return true
+		}
+	}
+	return false
+}
+
+func joinOps(ops []Op) string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, " ")
+}
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses sizes such as 512, 10KB or 1.5MB. Units are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+
+	number, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q (use a number with an optional unit like 1MB)", s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q (use B, KB, MB, GB or TB)", s[i:])
+	}
+
+	return int64(number * float64(unit)), nil
+}
@@ -0,0 +1,166 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+
+// parseTime accepts RFC3339 and "-<duration>" offsets from testNow.
+func parseTime(s string) (time.Time, error) {
+	if offset, ok := strings.CutPrefix(s, "-"); ok {
+		d, err := time.ParseDuration(offset)
+		return testNow.Add(-d), err
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// format prints a tree with explicit grouping, to compare parses.
+func format(node Node) string {
+	switch n := node.(type) {
+	case *And:
+		return "(" + format(n.Left) + " AND " + format(n.Right) + ")"
+	case *Or:
+		return "(" + format(n.Left) + " OR " + format(n.Right) + ")"
+	case *Not:
+		return "NOT " + format(n.Expr)
+	case *Comparison:
+		switch n.Field {
+		case "time":
+			return fmt.Sprintf("%s%s%s", n.Field, n.Op, n.Time.Format(time.RFC3339))
+		case "size":
+			return fmt.Sprintf("%s%s%d", n.Field, n.Op, n.Size)
+		}
+		return fmt.Sprintf("%s%s%q", n.Field, n.Op, n.Value)
+	}
+	return "?"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`type:go`, `type:"go"`},
+		{`type:go event:write`, `(type:"go" AND event:"WRITE")`},
+		{`type:go AND event:WRITE OR size > 1MB`, `((type:"go" AND event:"WRITE") OR size>1048576)`},
+		{`type:go and (event:write or event:create)`, `(type:"go" AND (event:"WRITE" OR event:"CREATE"))`},
+		{`NOT path:vendor AND NOT (dir:/tmp)`, `(NOT path:"vendor" AND NOT dir:"/tmp")`},
+		{`path:~"internal/" name:"my file.txt"`, `(path:~"internal/" AND name:"my file.txt")`},
+		{`name:"say \"hi\""`, `name:"say \"hi\""`},
+		{`ext:go file:main.go directory:/src`, `((type:"go" AND name:"main.go") AND dir:"/src")`},
+		{`time > -2h AND time<=2026-03-14T08:30:00Z`, `(time>2026-03-14T07:00:00Z AND time<=2026-03-14T08:30:00Z)`},
+		{`size >= 1.5KB size != 0`, `(size>=1536 AND size!=0)`},
+		{`type = go OR type != md`, `(type="go" OR type!="md")`},
+		{`(type:go)`, `type:"go"`},
+		{`event:~^\w+$ OR event:~"cre|wri"`, `(event:~"^\\w+$" OR event:~"cre|wri")`},
+		{`event = remove`, `event="REMOVE"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			q, err := Parse(tt.in, parseTime)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got := format(q.Root); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+			if q.Input != tt.in {
+				t.Errorf("Input = %q", q.Input)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in       string
+		pos, end int
+		msg      string
+	}{
+		{``, 0, 0, "empty expression"},
+		{`   `, 0, 0, "empty expression"},
+		{`color:red`, 0, 5, `unknown field "color"`},
+		{`type`, 4, 4, `expected an operator after "type"`},
+		{`type:`, 5, 5, "expected a value"},
+		{`size:1MB`, 4, 5, `operator ":" cannot be used with size`},
+		{`time > yesterday`, 7, 16, `invalid time "yesterday"`},
+		{`size > 10XB`, 7, 11, `unknown size unit "XB"`},
+		{`path:~"(("`, 6, 10, "invalid regular expression"},
+		{`name:"open`, 5, 10, "unterminated string"},
+		{`type:go AND (event:write`, 12, 13, "unclosed parenthesis"},
+		{`type:go AND (`, 13, 13, "expected a condition"},
+		{`type:go )`, 8, 9, `unexpected ")"`},
+		{`type:go AND 42`, 12, 14, `expected a field name, found "42"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := Parse(tt.in, parseTime)
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse(%q) = %v, want an *Error", tt.in, err)
+			}
+			if parseErr.Pos != tt.pos || parseErr.End != tt.end || !strings.HasPrefix(parseErr.Msg, tt.msg) {
+				t.Errorf("Parse(%q) = %q at %d-%d, want %q at %d-%d",
+					tt.in, parseErr.Msg, parseErr.Pos, parseErr.End, tt.msg, tt.pos, tt.end)
+			}
+		})
+	}
+}
+
+func TestErrorCaret(t *testing.T) {
+	_, err := Parse(`type:go AND colour:red`, parseTime)
+	want := "unknown field \"colour\" (expected type, event, path, name, dir, time or size) at column 13\n" +
+		"  type:go AND colour:red\n" +
+		"              ^^^^^^"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v\nwant:\n%s", err, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     int64
+		hasError bool
+	}{
+		{in: "0", want: 0},
+		{in: "512", want: 512},
+		{in: "512b", want: 512},
+		{in: "10KB", want: 10 << 10},
+		{in: "10k", want: 10 << 10},
+		{in: "1.5MB", want: 3 << 19},
+		{in: "2MiB", want: 2 << 20},
+		{in: "1g", want: 1 << 30},
+		{in: "1TB", want: 1 << 40},
+		{in: "", hasError: true},
+		{in: "MB", hasError: true},
+		{in: "-1MB", hasError: true},
+		{in: "1..5MB", hasError: true},
+		{in: "10XB", hasError: true},
+		{in: "10 MB", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("ParseSize(%q) = %d, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSize(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
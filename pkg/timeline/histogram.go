@@ -0,0 +1,123 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/fatih/color"
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+var bucketLayouts = map[database.Interval]string{
+	database.IntervalMinute: "2006-01-02 15:04",
+	database.IntervalHour:   "2006-01-02 15:00",
+	database.IntervalDay:    "2006-01-02 Mon",
+	database.IntervalWeek:   "2006-01-02",
+}
+
+// RenderHistogram draws one bar per bucket with the total count, followed by
+// a column per series when the histogram is grouped.
+func (r *Renderer) RenderHistogram(histogram *database.Histogram) string {
+	if len(histogram.Buckets) == 0 {
+		return "No events found.\n"
+	}
+
+	totals := make([]int64, len(histogram.Buckets))
+	var largest int64
+	for _, series := range histogram.Series {
+		for i, count := range series.Counts {
+			totals[i] += count
+			largest = max(largest, totals[i])
+		}
+	}
+
+	grouped := len(histogram.Series) > 1 || histogram.Series[0].Name != "all"
+	countWidth := len(fmt.Sprint(largest))
+	widths := make([]int, len(histogram.Series))
+	for i, series := range histogram.Series {
+		widths[i] = max(utf8.RuneCountInString(series.Name), len(fmt.Sprint(series.Total)))
+	}
+
+	var builder strings.Builder
+	builder.WriteString(r.histogramHeader(histogram))
+	builder.WriteString("\n\n")
+
+	layout := bucketLayouts[histogram.Interval]
+	labelWidth := len(layout)
+	if grouped {
+		builder.WriteString(fmt.Sprintf("    %-*s  %-*s  %*s", labelWidth, "", barWidth, "", countWidth, ""))
+		for i, series := range histogram.Series {
+			builder.WriteString("  " + r.seriesName(fmt.Sprintf("%*s", widths[i], series.Name), i))
+		}
+		builder.WriteString("\n")
+	}
+
+	for i, bucket := range histogram.Buckets {
+		builder.WriteString(fmt.Sprintf("    %-*s  %s  %*d", labelWidth, bucket.Format(layout),
+			r.bar(totals[i], largest), countWidth, totals[i]))
+		if grouped {
+			for j, series := range histogram.Series {
+				builder.WriteString(fmt.Sprintf("  %*d", widths[j], series.Counts[i]))
+			}
+		}
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// RenderSparklines draws each series on one line, scaled to the busiest
+// bucket of any series.
+func (r *Renderer) RenderSparklines(histogram *database.Histogram) string {
+	if len(histogram.Buckets) == 0 {
+		return "No events found.\n"
+	}
+
+	var largest int64
+	nameWidth := 0
+	for _, series := range histogram.Series {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(series.Name))
+		for _, count := range series.Counts {
+			largest = max(largest, count)
+		}
+	}
+
+	layout := bucketLayouts[histogram.Interval]
+	first, last := histogram.Buckets[0], histogram.Buckets[len(histogram.Buckets)-1]
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%s .. %s (per %s)\n", first.Format(layout), last.Format(layout), histogram.Interval))
+	for i, series := range histogram.Series {
+		line := make([]rune, len(series.Counts))
+		for j, count := range series.Counts {
+			line[j] = ' '
+			if count > 0 {
+				line[j] = sparkLevels[int(count*int64(len(sparkLevels)-1)/largest)]
+			}
+		}
+		name := r.seriesName(series.Name+strings.Repeat(" ", nameWidth-utf8.RuneCountInString(series.Name)), i)
+		builder.WriteString(fmt.Sprintf("%s  %s  %d\n", name, string(line), series.Total))
+	}
+
+	return builder.String()
+}
+
+func (r *Renderer) histogramHeader(histogram *database.Histogram) string {
+	title := fmt.Sprintf("Events per %s", histogram.Interval)
+	if r.colorEnabled {
+		return color.New(color.FgCyan, color.Bold).Sprintf("═══ %s ═══", title)
+	}
+	return fmt.Sprintf("=== %s ===", title)
+}
+
+var seriesColors = []color.Attribute{color.FgBlue, color.FgGreen, color.FgMagenta, color.FgYellow, color.FgCyan, color.FgRed}
+
+func (r *Renderer) seriesName(name string, i int) string {
+	if !r.colorEnabled {
+		return name
+	}
+	return color.New(seriesColors[i%len(seriesColors)], color.Bold).Sprint(name)
+}
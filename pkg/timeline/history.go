@@ -0,0 +1,118 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/history"
+	"github.com/fatih/color"
+)
+
+// RenderHistory lists the steps of a file's history with the time since the
+// previous step, how writes were coalesced and how the size changed.
+func (r *Renderer) RenderHistory(path string, steps []*history.Step) string {
+	if len(steps) == 0 {
+		return fmt.Sprintf("No events found for %s.\n", path)
+	}
+
+	var builder strings.Builder
+	title := "History of " + path
+	if r.colorEnabled {
+		builder.WriteString(color.New(color.FgCyan, color.Bold).Sprintf("═══ %s ═══", title))
+	} else {
+		builder.WriteString(fmt.Sprintf("=== %s ===", title))
+	}
+	builder.WriteString("\n\n")
+
+	var sizes []int64
+	var lastSize *int64
+	paths := make(map[string]bool)
+	events := 0
+
+	for i, step := range steps {
+		paths[step.Path] = true
+		events += step.Count
+
+		gap := ""
+		if i > 0 {
+			gap = "+" + formatSpan(step.Start.Sub(steps[i-1].End))
+		}
+
+		detail := step.Path
+		switch {
+		case step.RenamedTo != "":
+			detail += " → " + step.RenamedTo
+		case step.RenamedFrom != "":
+			detail += " ← " + step.RenamedFrom
+		}
+		if step.Count > 1 {
+			detail += fmt.Sprintf("  ×%d over %s", step.Count, formatSpan(step.End.Sub(step.Start)))
+		}
+		if step.Size != nil {
+			detail += "  " + formatSize(*step.Size)
+			if lastSize != nil && *step.Size != *lastSize {
+				delta := *step.Size - *lastSize
+				sign := "+"
+				if delta < 0 {
+					sign, delta = "-", -delta
+				}
+				detail += fmt.Sprintf(" (%s%s)", sign, formatSize(delta))
+			}
+			lastSize = step.Size
+			sizes = append(sizes, *step.Size)
+		}
+
+		builder.WriteString(fmt.Sprintf("  %s  %-8s  %s  %s\n", step.Start.Format("2006-01-02 15:04:05"),
+			gap, r.colorizeEventType(step.EventType), detail))
+	}
+
+	first, last := steps[0], steps[len(steps)-1]
+	builder.WriteString(fmt.Sprintf("\n  %d events over %s", events, formatSpan(last.End.Sub(first.Start))))
+	if len(paths) > 1 {
+		builder.WriteString(fmt.Sprintf(" across %d paths", len(paths)))
+	}
+	builder.WriteString("\n")
+
+	if len(sizes) > 1 {
+		builder.WriteString(fmt.Sprintf("  Size: %s  %s .. %s\n", sparkline(sizes),
+			formatSize(sizes[0]), formatSize(sizes[len(sizes)-1])))
+	}
+
+	return builder.String()
+}
+
+// sparkline scales values between their minimum and maximum.
+func sparkline(values []int64) string {
+	lowest, highest := values[0], values[0]
+	for _, value := range values {
+		lowest, highest = min(lowest, value), max(highest, value)
+	}
+
+	line := make([]rune, len(values))
+	for i, value := range values {
+		level := len(sparkLevels) / 2
+		if highest > lowest {
+			level = int((value - lowest) * int64(len(sparkLevels)-1) / (highest - lowest))
+		}
+		line[i] = sparkLevels[level]
+	}
+
+	return string(line)
+}
+
+// formatSize renders a byte count in powers of 1024, matching the units the
+// query language accepts.
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,79 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/fatih/color"
+)
+
+func (r *Renderer) RenderSearch(results []*database.SearchResult, terms []string) string {
+	if len(results) == 0 {
+		return "No matching files found.\n"
+	}
+
+	var builder strings.Builder
+	for i, result := range results {
+		builder.WriteString(fmt.Sprintf("%3d. %s\n", i+1, r.highlight(result.FilePath, terms)))
+		builder.WriteString(fmt.Sprintf("     %d events, last seen %s\n",
+			result.Events, result.LastSeen.Format("2006-01-02 15:04:05")))
+	}
+
+	return builder.String()
+}
+
+// highlight marks every word in text that starts with one of terms, the same
+// prefix matching the search index uses.
+func (r *Renderer) highlight(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	marked := make([]bool, len(text))
+
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			if start == 0 || !isWordChar(rune(lower[start-1])) {
+				for j := start; j < start+len(term); j++ {
+					marked[j] = true
+				}
+			}
+			offset = start + 1
+		}
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			builder.WriteString(r.mark(text[i:j]))
+		} else {
+			builder.WriteString(text[i:j])
+		}
+		i = j
+	}
+
+	return builder.String()
+}
+
+func (r *Renderer) mark(text string) string {
+	if r.colorEnabled {
+		return color.New(color.FgYellow, color.Bold, color.Underline).Sprint(text)
+	}
+	return "[" + text + "]"
+}
+
+func isWordChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,140 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/fatih/color"
+)
+
+const barWidth = 30
+
+// barEighths draws the fractional end of a bar in eighths of a cell.
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+func (r *Renderer) RenderStats(stats *database.Stats) string {
+	if stats.TotalEvents == 0 {
+		return "No events found.\n"
+	}
+
+	var builder strings.Builder
+	if r.colorEnabled {
+		builder.WriteString(color.New(color.FgCyan, color.Bold).Sprint("═══ File System Statistics ═══"))
+	} else {
+		builder.WriteString("=== File System Statistics ===")
+	}
+	builder.WriteString("\n\n")
+
+	builder.WriteString(fmt.Sprintf("  Events:  %d\n", stats.TotalEvents))
+	builder.WriteString(fmt.Sprintf("  First:   %s\n", stats.FirstEvent.Format("2006-01-02 15:04:05")))
+	builder.WriteString(fmt.Sprintf("  Last:    %s\n", stats.LastEvent.Format("2006-01-02 15:04:05")))
+	builder.WriteString(fmt.Sprintf("  Span:    %s\n", formatSpan(stats.LastEvent.Sub(*stats.FirstEvent))))
+
+	eventTypes := make([]database.Count, 0, len(stats.ByEventType))
+	for eventType, count := range stats.ByEventType {
+		eventTypes = append(eventTypes, database.Count{Key: eventType, Count: count})
+	}
+	sort.Slice(eventTypes, func(i, j int) bool {
+		if eventTypes[i].Count != eventTypes[j].Count {
+			return eventTypes[i].Count > eventTypes[j].Count
+		}
+		return eventTypes[i].Key < eventTypes[j].Key
+	})
+	r.writeTable(&builder, "Event types", eventTypes, stats.TotalEvents)
+
+	fileTypes := make([]database.Count, len(stats.TopFileTypes))
+	for i, count := range stats.TopFileTypes {
+		fileTypes[i] = count
+		if count.Key == "" {
+			fileTypes[i].Key = "(none)"
+		}
+	}
+	r.writeTable(&builder, "File types", fileTypes, stats.TotalEvents)
+	r.writeTable(&builder, "Top directories", stats.TopDirectories, stats.TotalEvents)
+	r.writeTable(&builder, "Busiest files", stats.TopFiles, stats.TotalEvents)
+
+	if stats.ByHour != nil {
+		hours := make([]database.Count, len(stats.ByHour))
+		for hour, count := range stats.ByHour {
+			hours[hour] = database.Count{Key: fmt.Sprintf("%02d:00", hour), Count: count}
+		}
+		r.writeTable(&builder, "Hours of day", hours, stats.TotalEvents)
+	}
+
+	if stats.ByWeekday != nil {
+		// Weeks start on Monday
+		days := make([]database.Count, 0, 7)
+		for i := 1; i <= 7; i++ {
+			day := time.Weekday(i % 7)
+			days = append(days, database.Count{Key: day.String(), Count: stats.ByWeekday[day]})
+		}
+		r.writeTable(&builder, "Days of week", days, stats.TotalEvents)
+	}
+
+	return builder.String()
+}
+
+// writeTable writes one row per count with a bar scaled to the largest count
+// and its share of total.
+func (r *Renderer) writeTable(builder *strings.Builder, title string, counts []database.Count, total int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	builder.WriteString("\n")
+	builder.WriteString(r.dateHeader(title))
+	builder.WriteString("\n")
+
+	labelWidth, countWidth := 0, 0
+	var largest int64
+	for _, count := range counts {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(count.Key))
+		countWidth = max(countWidth, len(fmt.Sprint(count.Count)))
+		largest = max(largest, count.Count)
+	}
+
+	for _, count := range counts {
+		label := count.Key + strings.Repeat(" ", labelWidth-utf8.RuneCountInString(count.Key))
+		bar := r.bar(count.Count, largest)
+		builder.WriteString(fmt.Sprintf("    %s  %*d  %s  %5.1f%%\n", label, countWidth, count.Count,
+			bar, float64(count.Count)*100/float64(total)))
+	}
+}
+
+// bar draws value as a bar up to barWidth cells long, padded to that width.
+func (r *Renderer) bar(value, largest int64) string {
+	eighths := 0
+	if largest > 0 {
+		eighths = int(value * barWidth * 8 / largest)
+	}
+	if eighths == 0 && value > 0 {
+		eighths = 1
+	}
+
+	bar := strings.Repeat("█", eighths/8) + barEighths[eighths%8]
+	padding := strings.Repeat(" ", barWidth-utf8.RuneCountInString(bar))
+	if r.colorEnabled {
+		bar = color.New(color.FgBlue).Sprint(bar)
+	}
+
+	return bar + padding
+}
+
+// formatSpan renders a duration in the largest units that matter, like "3d 4h".
+func formatSpan(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+}
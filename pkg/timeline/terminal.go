@@ -0,0 +1,94 @@
+package timeline
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// minPathWidth is the narrowest a truncated path gets, however little room
+// the rest of the line leaves.
+const minPathWidth = 20
+
+// TerminalWidth returns the width of the terminal on stdout, falling back to
+// $COLUMNS, or 0 when stdout is not a terminal.
+func TerminalWidth() int {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return 0
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
+
+// SetWidth sets the line width paths are truncated to fit; 0 disables
+// truncation.
+func (r *Renderer) SetWidth(width int) {
+	r.width = width
+}
+
+// SetRelativeTime shows event times as the time elapsed since then, such as
+// "3m ago", instead of the time of day.
+func (r *Renderer) SetRelativeTime(relative bool) {
+	r.relative = relative
+}
+
+// clock formats the time of an event line.
+func (r *Renderer) clock(t time.Time) string {
+	if r.relative {
+		return fmt.Sprintf("%-8s", formatAgo(time.Since(t)))
+	}
+	return t.Format("15:04:05")
+}
+
+// fit truncates path to what is left of the line after used columns.
+func (r *Renderer) fit(path string, used int) string {
+	if r.width <= 0 {
+		return path
+	}
+	return truncateMiddle(path, max(r.width-used, minPathWidth))
+}
+
+// truncateMiddle shortens s to width runes by replacing its middle with an
+// ellipsis, keeping the start of a path and the file name at its end.
+func truncateMiddle(s string, width int) string {
+	length := utf8.RuneCountInString(s)
+	if length <= width {
+		return s
+	}
+	if width < 2 {
+		return "…"
+	}
+
+	runes := []rune(s)
+	tail := (width - 1) / 2
+	head := width - 1 - tail
+	return string(runes[:head]) + "…" + string(runes[length-tail:])
+}
+
+// formatAgo renders an elapsed duration in its largest whole unit.
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "soon"
+	case d < time.Second:
+		return "just now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return fmt.Sprintf("%dy ago", int(d.Hours()/(365*24)))
+}
@@ -2,6 +2,7 @@ package timeline
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
@@ -10,38 +11,122 @@ import (
 
 type Renderer struct {
 	colorEnabled bool
+	width        int
+	relative     bool
+
+	tree         bool
+	treeRoot     string
+	treeInterval database.Interval
 }
 
+// NewRenderer returns a renderer sized to the terminal on stdout. Colors are
+// also left off when NO_COLOR is set or stdout is not a terminal.
 func NewRenderer(colorEnabled bool) *Renderer {
-	return &Renderer{colorEnabled: colorEnabled}
+	return &Renderer{
+		colorEnabled: colorEnabled && !color.NoColor,
+		width:        TerminalWidth(),
+	}
 }
 
 func (r *Renderer) Render(events []*database.Event) string {
-	if len(events) == 0 {
-		return "No events found.\n"
+	var builder strings.Builder
+
+	stream := r.NewStream(&builder)
+	for _, event := range events {
+		stream.Write(event)
 	}
+	stream.Close()
 
-	var builder strings.Builder
+	return builder.String()
+}
 
-	builder.WriteString(r.header())
-	builder.WriteString("\n")
+// Stream renders events one at a time as they arrive, so a timeline of any
+// length can be written without holding it in memory.
+type Stream struct {
+	r           *Renderer
+	w           io.Writer
+	currentDate string
+	count       int
+	err         error
+
+	// group collects the current bucket's events in tree mode.
+	group *treeDir
+}
 
-	var currentDate string
-	for _, event := range events {
-		eventDate := event.Timestamp.Format("2006-01-02")
-		if eventDate != currentDate {
-			currentDate = eventDate
-			builder.WriteString(r.dateHeader(eventDate))
-			builder.WriteString("\n")
+func (r *Renderer) NewStream(w io.Writer) *Stream {
+	return &Stream{r: r, w: w}
+}
+
+func (s *Stream) Write(event *database.Event) error {
+	if s.count == 0 {
+		s.print(s.r.header())
+		s.print("\n")
+	}
+	s.count++
+
+	if s.r.tree {
+		bucket := s.r.treeBucket(event.Timestamp)
+		if bucket != s.currentDate {
+			s.writeGroup()
+			s.currentDate = bucket
+			s.print(s.r.dateHeader(bucket))
+			s.print("\n")
+		}
+		if s.group == nil {
+			s.group = newTreeDir("")
 		}
+		s.r.addToTree(s.group, event)
+		return s.err
+	}
 
-		builder.WriteString(r.formatEvent(event))
-		builder.WriteString("\n")
+	eventDate := event.Timestamp.Format("2006-01-02")
+	if eventDate != s.currentDate {
+		s.currentDate = eventDate
+		s.print(s.r.dateHeader(eventDate))
+		s.print("\n")
 	}
 
-	builder.WriteString(r.footer(len(events)))
+	s.print(s.r.formatEvent(event))
+	s.print("\n")
 
-	return builder.String()
+	return s.err
+}
+
+// Flush reports any write error so far. In tree mode it first writes the
+// events buffered for the current bucket.
+func (s *Stream) Flush() error {
+	s.writeGroup()
+	return s.err
+}
+
+// Close writes the footer, or a notice if no events were written.
+func (s *Stream) Close() error {
+	if s.count == 0 {
+		s.print("No events found.\n")
+		return s.err
+	}
+
+	s.writeGroup()
+	s.print(s.r.footer(s.count))
+	return s.err
+}
+
+// writeGroup renders and clears the tree-mode group, if any.
+func (s *Stream) writeGroup() {
+	if s.group == nil {
+		return
+	}
+
+	var builder strings.Builder
+	s.r.renderTree(&builder, s.group, 0)
+	s.print(builder.String())
+	s.group = nil
+}
+
+func (s *Stream) print(text string) {
+	if s.err == nil {
+		_, s.err = io.WriteString(s.w, text)
+	}
 }
 
 func (r *Renderer) header() string {
@@ -59,10 +144,11 @@ func (r *Renderer) dateHeader(date string) string {
 }
 
 func (r *Renderer) formatEvent(event *database.Event) string {
-	timestamp := event.Timestamp.Format("15:04:05")
+	timestamp := r.clock(event.Timestamp)
 	eventType := r.colorizeEventType(event.EventType)
-	filePath := event.FilePath
 	fileType := event.FileType
+	used := 4 + len(timestamp) + 2 + max(len(event.EventType), 7) + 2 + len(fileType) + 3
+	filePath := r.fit(event.FilePath, used)
 
 	return fmt.Sprintf("    %s  %s  %s [%s]", timestamp, eventType, filePath, fileType)
 }
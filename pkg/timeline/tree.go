@@ -0,0 +1,177 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/fatih/color"
+)
+
+// treeDir is a directory in a tree-mode group. Count includes every event
+// below it.
+type treeDir struct {
+	name  string
+	dirs  map[string]*treeDir
+	files map[string]*treeFile
+	count int
+}
+
+// treeFile holds a file's events as runs of the same event type.
+type treeFile struct {
+	runs  []*treeRun
+	count int
+}
+
+type treeRun struct {
+	eventType  string
+	start, end time.Time
+	count      int
+}
+
+func newTreeDir(name string) *treeDir {
+	return &treeDir{name: name, dirs: make(map[string]*treeDir), files: make(map[string]*treeFile)}
+}
+
+// SetTree switches the renderer to tree mode: the events of each interval
+// bucket are grouped by directory, with paths shown relative to root and
+// runs of the same event on a file collapsed into one line.
+func (r *Renderer) SetTree(root string, interval database.Interval) {
+	r.tree = true
+	r.treeRoot = strings.TrimSuffix(root, "/")
+	r.treeInterval = interval
+}
+
+// addToTree files event under its path relative to the renderer's root.
+func (r *Renderer) addToTree(root *treeDir, event *database.Event) {
+	path := event.FilePath
+	if r.treeRoot != "" && strings.HasPrefix(path, r.treeRoot+"/") {
+		path = path[len(r.treeRoot)+1:]
+	}
+
+	parts := splitTreePath(path)
+	dir := root
+	dir.count++
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := dir.dirs[part]
+		if !ok {
+			child = newTreeDir(part)
+			dir.dirs[part] = child
+		}
+		child.count++
+		dir = child
+	}
+
+	name := parts[len(parts)-1]
+	file, ok := dir.files[name]
+	if !ok {
+		file = &treeFile{}
+		dir.files[name] = file
+	}
+	file.count++
+
+	if n := len(file.runs); n > 0 && file.runs[n-1].eventType == event.EventType {
+		last := file.runs[n-1]
+		last.end = event.Timestamp
+		last.count++
+		return
+	}
+	file.runs = append(file.runs, &treeRun{
+		eventType: event.EventType,
+		start:     event.Timestamp,
+		end:       event.Timestamp,
+		count:     1,
+	})
+}
+
+// renderTree writes a group's directories and files in name order. A
+// directory holding only one subdirectory is merged with it into one line.
+func (r *Renderer) renderTree(builder *strings.Builder, dir *treeDir, depth int) {
+	indent := strings.Repeat("  ", depth+2)
+
+	for _, name := range sortedKeys(dir.dirs) {
+		child := dir.dirs[name]
+		label := name
+		for len(child.files) == 0 && len(child.dirs) == 1 {
+			for next, grandchild := range child.dirs {
+				label += "/" + next
+				child = grandchild
+			}
+		}
+
+		label = r.fit(label+"/", len(indent)+len(fmt.Sprint(child.count))+3)
+		builder.WriteString(fmt.Sprintf("%s%s %s\n", indent, r.treeDirName(label), r.treeCount(child.count)))
+		r.renderTree(builder, child, depth+1)
+	}
+
+	for _, name := range sortedKeys(dir.files) {
+		file := dir.files[name]
+		name = r.fit(name, len(indent)+len(fmt.Sprint(file.count))+3)
+		builder.WriteString(fmt.Sprintf("%s%s %s\n", indent, name, r.treeCount(file.count)))
+		for _, run := range file.runs {
+			line := fmt.Sprintf("%s  %s  %s", indent, r.clock(run.start), r.colorizeEventType(run.eventType))
+			if run.count > 1 {
+				line += fmt.Sprintf("  ×%d until %s", run.count, strings.TrimSpace(r.clock(run.end)))
+			}
+			builder.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+}
+
+// treeBucket returns the heading of the bucket an event belongs to.
+func (r *Renderer) treeBucket(t time.Time) string {
+	start := r.treeInterval.Start(t)
+	switch r.treeInterval {
+	case database.IntervalMinute:
+		return start.Format("2006-01-02 15:04")
+	case database.IntervalHour:
+		return start.Format("2006-01-02 15:00")
+	case database.IntervalWeek:
+		return "Week of " + start.Format("2006-01-02")
+	}
+	return start.Format("2006-01-02")
+}
+
+func (r *Renderer) treeDirName(name string) string {
+	if r.colorEnabled {
+		return color.New(color.FgBlue, color.Bold).Sprint(name)
+	}
+	return name
+}
+
+func (r *Renderer) treeCount(count int) string {
+	text := fmt.Sprintf("(%d)", count)
+	if r.colorEnabled {
+		return color.New(color.Faint).Sprint(text)
+	}
+	return text
+}
+
+// splitTreePath splits a path into its directories and file name, keeping
+// a leading "/" as the first directory of absolute paths.
+func splitTreePath(path string) []string {
+	var parts []string
+	if strings.HasPrefix(path, "/") {
+		parts = append(parts, "")
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, path)
+	}
+	return parts
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+// Package timespec parses the time expressions accepted on the command line:
+// absolute timestamps, dates, clock times, relative offsets and a few words
+// such as "yesterday" and "last monday".
+package timespec
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats without a zone are read in the caller's location.
+var layouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	dateLayout,
+}
+
+const dateLayout = "2006-01-02"
+
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+var unixPattern = regexp.MustCompile(`^@?\d{9,}$`)
+
+var durationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)([a-zµ]+)`)
+
+// Parse converts s to a point in time relative to now. Times without an
+// explicit zone are interpreted in loc. Accepted forms:
+//
+//	2025-12-19T14:00:00Z    RFC3339, with or without fractional seconds
+//	2025-12-19 14:00        date and clock time
+//	2025-12-19              midnight on that date
+//	14:00                   that time today
+//	now, today, yesterday   today and yesterday start at midnight
+//	last monday             midnight on the most recent Monday before today
+//	-3d, -2w, -1h30m        offsets before now; "3d ago" works too
+//	1734567890              unix seconds, or milliseconds with 13+ digits
+func Parse(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	t, _, err := parse(s, now, loc)
+	return t, err
+}
+
+// ParseEnd is Parse for the end of a range. Forms naming a whole day, such as
+// a date, "today", "yesterday" or "last monday", give the end of that day
+// rather than its midnight, so the range includes the day.
+func ParseEnd(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	t, day, err := parse(s, now, loc)
+	if err != nil || !day {
+		return t, err
+	}
+
+	// The last microsecond, the finest precision PostgreSQL stores
+	return t.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+}
+
+// parse implements Parse, also reporting whether s names a whole day.
+func parse(s string, now time.Time, loc *time.Location) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	now = now.In(loc)
+
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, false, nil
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, layout == dateLayout, nil
+		}
+	}
+
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), false, nil
+		}
+	}
+
+	if unixPattern.MatchString(s) {
+		t, err := parseUnix(strings.TrimPrefix(s, "@"))
+		return t.In(loc), false, err
+	}
+
+	lower := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch lower {
+	case "now":
+		return now, false, nil
+	case "today":
+		return midnight, true, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), true, nil
+	}
+
+	if name, ok := strings.CutPrefix(lower, "last "); ok {
+		if weekday, ok := weekdays[name]; ok {
+			days := int(now.Weekday()-weekday+7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return midnight.AddDate(0, 0, -days), true, nil
+		}
+	}
+
+	offset, ok := strings.CutPrefix(lower, "-")
+	if !ok {
+		offset, ok = strings.CutSuffix(lower, " ago")
+	}
+	if ok {
+		if d, err := ParseDuration(strings.ReplaceAll(offset, " ", "")); err == nil {
+			return now.Add(-d), false, nil
+		}
+	}
+
+	return time.Time{}, false, fmt.Errorf("invalid time %q (use RFC3339, 2006-01-02, 15:04, -24h, -3d, yesterday, last monday or a unix timestamp)", s)
+}
+
+// ParseDuration extends time.ParseDuration with days (d) and weeks (w).
+func ParseDuration(s string) (time.Duration, error) {
+	matches := durationPart.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 || matches[0][0] != 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	end := 0
+	for _, m := range matches {
+		if m[0] != end {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		end = m[1]
+
+		number, unit := s[m[2]:m[3]], s[m[4]:m[5]]
+		var scale time.Duration
+		switch unit {
+		case "d":
+			scale = 24 * time.Hour
+		case "w":
+			scale = 7 * 24 * time.Hour
+		default:
+			d, err := time.ParseDuration(number + unit)
+			if err != nil {
+				return 0, err
+			}
+			total += d
+			continue
+		}
+
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, err
+		}
+		total += time.Duration(value * float64(scale))
+	}
+	if end != len(s) {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return total, nil
+}
+
+func parseUnix(digits string) (time.Time, error) {
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid unix timestamp %q", digits)
+	}
+	if len(digits) >= 13 {
+		return time.UnixMilli(n), nil
+	}
+	return time.Unix(n, 0), nil
+}
@@ -0,0 +1,116 @@
+package timespec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	// A Wednesday
+	now := time.Date(2026, 3, 18, 15, 30, 0, 0, loc)
+
+	tests := []struct {
+		in       string
+		want     time.Time
+		wantEnd  time.Time
+		hasError bool
+	}{
+		{in: "2025-12-19T14:00:00Z", want: time.Date(2025, 12, 19, 14, 0, 0, 0, time.UTC)},
+		{in: "2025-12-19T14:00:00.5+01:00", want: time.Date(2025, 12, 19, 13, 0, 0, 5e8, time.UTC)},
+		{in: "2025-12-19 14:00", want: time.Date(2025, 12, 19, 14, 0, 0, 0, loc)},
+		{in: "2025-12-19T14:00:30", want: time.Date(2025, 12, 19, 14, 0, 30, 0, loc)},
+		{in: "2025-12-19", want: time.Date(2025, 12, 19, 0, 0, 0, 0, loc),
+			wantEnd: time.Date(2025, 12, 19, 23, 59, 59, 999999000, loc)},
+		{in: "09:15", want: time.Date(2026, 3, 18, 9, 15, 0, 0, loc)},
+		{in: "now", want: now},
+		{in: " Today ", want: time.Date(2026, 3, 18, 0, 0, 0, 0, loc),
+			wantEnd: time.Date(2026, 3, 18, 23, 59, 59, 999999000, loc)},
+		{in: "yesterday", want: time.Date(2026, 3, 17, 0, 0, 0, 0, loc),
+			wantEnd: time.Date(2026, 3, 17, 23, 59, 59, 999999000, loc)},
+		{in: "last monday", want: time.Date(2026, 3, 16, 0, 0, 0, 0, loc),
+			wantEnd: time.Date(2026, 3, 16, 23, 59, 59, 999999000, loc)},
+		{in: "last wednesday", want: time.Date(2026, 3, 11, 0, 0, 0, 0, loc),
+			wantEnd: time.Date(2026, 3, 11, 23, 59, 59, 999999000, loc)},
+		{in: "-24h", want: now.Add(-24 * time.Hour)},
+		{in: "-1d12h", want: now.Add(-36 * time.Hour)},
+		{in: "2w ago", want: now.AddDate(0, 0, -14)},
+		{in: "1734567890", want: time.Unix(1734567890, 0)},
+		{in: "@1734567890123", want: time.UnixMilli(1734567890123)},
+		{in: "", hasError: true},
+		{in: "last month", hasError: true},
+		{in: "2025-13-01", hasError: true},
+		{in: "-3x", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in, now, loc)
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+
+			// Only whole days move to their end
+			wantEnd := tt.wantEnd
+			if wantEnd.IsZero() {
+				wantEnd = tt.want
+			}
+			end, err := ParseEnd(tt.in, now, loc)
+			if err != nil {
+				t.Fatalf("ParseEnd(%q): %v", tt.in, err)
+			}
+			if !end.Equal(wantEnd) {
+				t.Errorf("ParseEnd(%q) = %v, want %v", tt.in, end, wantEnd)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     time.Duration
+		hasError bool
+	}{
+		{in: "90s", want: 90 * time.Second},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "3d", want: 72 * time.Hour},
+		{in: "2w", want: 14 * 24 * time.Hour},
+		{in: "1.5d", want: 36 * time.Hour},
+		{in: "1w2d3h", want: (9*24 + 3) * time.Hour},
+		{in: "500ms", want: 500 * time.Millisecond},
+		{in: "", hasError: true},
+		{in: "d", hasError: true},
+		{in: "3", hasError: true},
+		{in: "3y", hasError: true},
+		{in: " 3d", hasError: true},
+		{in: "3d!", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDuration(tt.in)
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("ParseDuration(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDuration(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// dirNode is a directory in the tree pane. Count includes the events of
+// every directory below it.
+type dirNode struct {
+	name      string
+	path      string
+	count     int64
+	depth     int
+	children  []*dirNode
+	collapsed bool
+}
+
+// buildTree arranges per-directory counts into a tree. Chains of
+// directories with a single child and no events of their own are merged
+// into one node, so deep roots such as /home/user/project take one row.
+func buildTree(counts []database.Count) []*dirNode {
+	root := &dirNode{}
+	own := make(map[*dirNode]int64)
+
+	for _, count := range counts {
+		node := root
+		for _, part := range splitPath(count.Key) {
+			var child *dirNode
+			for _, existing := range node.children {
+				if existing.name == part {
+					child = existing
+					break
+				}
+			}
+			if child == nil {
+				child = &dirNode{name: part, path: joinPath(node.path, part)}
+				node.children = append(node.children, child)
+			}
+			child.count += count.Count
+			node = child
+		}
+		own[node] += count.Count
+	}
+
+	var compact func(node *dirNode)
+	compact = func(node *dirNode) {
+		for len(node.children) == 1 && own[node] == 0 && node != root {
+			child := node.children[0]
+			node.name = strings.TrimSuffix(node.name, "/") + "/" + child.name
+			node.path = child.path
+			node.children = child.children
+			own[node] = own[child]
+		}
+		sort.Slice(node.children, func(i, j int) bool {
+			if node.children[i].count != node.children[j].count {
+				return node.children[i].count > node.children[j].count
+			}
+			return node.children[i].name < node.children[j].name
+		})
+		for _, child := range node.children {
+			compact(child)
+		}
+	}
+	compact(root)
+
+	return root.children
+}
+
+// flatten lists the visible nodes in display order.
+func flatten(nodes []*dirNode, depth int, rows []*dirNode) []*dirNode {
+	for _, node := range nodes {
+		node.depth = depth
+		rows = append(rows, node)
+		if !node.collapsed {
+			rows = flatten(node.children, depth+1, rows)
+		}
+	}
+	return rows
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	if strings.HasPrefix(path, "/") {
+		parts = append(parts, "/")
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func joinPath(parent, name string) string {
+	switch {
+	case parent == "":
+		return name
+	case strings.HasSuffix(parent, "/"):
+		return parent + name
+	}
+	return parent + "/" + name
+}
@@ -0,0 +1,612 @@
+// Package tui is an interactive terminal browser for the timeline: a
+// directory tree, a scrollable event list, a detail pane for the selected
+// event and a filter line using the query language.
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"github.com/BaseMax/go-fs-timeline/pkg/history"
+	"github.com/BaseMax/go-fs-timeline/pkg/query"
+	"github.com/gdamore/tcell/v2"
+)
+
+// Options configures the browser. Filter holds the filters given on the
+// command line; Expression is the initial query language expression, which
+// can be edited with "/".
+type Options struct {
+	Filter     database.QueryFilter
+	Expression string
+	ParseTime  func(string) (time.Time, error)
+	// Limit is how many of the newest matching events are loaded.
+	Limit int
+	// Poll is how often to check for new events.
+	Poll time.Duration
+	// Window pairs renames with creates in the detail pane's history.
+	Window time.Duration
+}
+
+type pane int
+
+const (
+	paneList pane = iota
+	paneTree
+)
+
+const (
+	treeWidth   = 32
+	detailLines = 9
+	// maxDirectories bounds the directories loaded into the tree pane.
+	maxDirectories = 2000
+	// treeRefresh is how often new events update the directory counts,
+	// which take several passes over the matching events.
+	treeRefresh = 30 * time.Second
+)
+
+var eventColors = map[string]tcell.Color{
+	"CREATE": tcell.ColorGreen,
+	"WRITE":  tcell.ColorBlue,
+	"REMOVE": tcell.ColorRed,
+	"RENAME": tcell.ColorPurple,
+	"CHMOD":  tcell.ColorOlive,
+}
+
+var (
+	styleDefault  = tcell.StyleDefault
+	styleHeader   = tcell.StyleDefault.Reverse(true)
+	styleSelected = tcell.StyleDefault.Reverse(true)
+	styleDim      = tcell.StyleDefault.Dim(true)
+	styleError    = tcell.StyleDefault.Foreground(tcell.ColorRed)
+)
+
+type app struct {
+	db      database.Store
+	options Options
+	screen  tcell.Screen
+
+	events   []*database.Event
+	selected int
+	offset   int
+	latestID int64
+	follow   bool
+
+	tree       []*dirNode
+	treeLoaded time.Time
+	rows       []*dirNode
+	treeCursor int
+	treeOffset int
+	directory  string
+	focus      pane
+	showTree   bool
+
+	expression string
+	editing    bool
+	input      []rune
+	message    string
+
+	detailID   int64
+	detailText []string
+}
+
+// Run opens the browser on the terminal and returns when the user quits.
+func Run(db database.Store, options Options) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer screen.Fini()
+
+	a := &app{
+		db:         db,
+		options:    options,
+		screen:     screen,
+		expression: options.Expression,
+		follow:     true,
+		showTree:   true,
+	}
+	if err := a.reload(true); err != nil {
+		a.message = err.Error()
+	}
+
+	// Wake the event loop periodically to pick up new events
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(options.Poll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				screen.PostEvent(tcell.NewEventInterrupt(nil))
+			}
+		}
+	}()
+
+	for {
+		a.draw()
+
+		switch event := screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventInterrupt:
+			a.poll()
+		case *tcell.EventKey:
+			if a.editing {
+				a.editKey(event)
+				continue
+			}
+			if quit := a.key(event); quit {
+				return nil
+			}
+		}
+	}
+}
+
+// filter combines the command line filters with the tree selection and the
+// current expression.
+func (a *app) filter(withDirectory bool) (database.QueryFilter, error) {
+	filter := a.options.Filter
+	if withDirectory && a.directory != "" {
+		filter.Directory = a.directory
+	}
+
+	if strings.TrimSpace(a.expression) != "" {
+		expression, err := query.Parse(a.expression, a.options.ParseTime)
+		if err != nil {
+			return filter, err
+		}
+		filter.Expression = expression
+	}
+
+	return filter, nil
+}
+
+// reload reads the newest matching events and, with tree set, the directory
+// counts.
+func (a *app) reload(tree bool) error {
+	filter, err := a.filter(true)
+	if err != nil {
+		return err
+	}
+
+	latestID, err := a.db.LatestEventID()
+	if err != nil {
+		return err
+	}
+
+	filter.Limit = a.options.Limit
+	events, err := a.db.QueryEvents(filter)
+	if err != nil {
+		return err
+	}
+	slices.Reverse(events)
+
+	var selectedID int64
+	if !a.follow && a.selected < len(a.events) {
+		selectedID = a.events[a.selected].ID
+	}
+
+	a.events = events
+	a.latestID = latestID
+	a.selected = len(events) - 1
+	for i, event := range events {
+		if event.ID == selectedID {
+			a.selected = i
+		}
+	}
+	a.selected = max(a.selected, 0)
+
+	if tree {
+		return a.reloadTree()
+	}
+	return nil
+}
+
+// reloadTree reads the directory counts, keeping collapsed directories
+// collapsed.
+func (a *app) reloadTree() error {
+	treeFilter, _ := a.filter(false)
+	stats, err := a.db.Stats(treeFilter, maxDirectories)
+	if err != nil {
+		return err
+	}
+
+	collapsed := make(map[string]bool)
+	for _, node := range a.rows {
+		if node.collapsed {
+			collapsed[node.path] = true
+		}
+	}
+	a.tree = buildTree(stats.TopDirectories)
+	a.rows = flatten(a.tree, 0, nil)
+	for _, node := range a.rows {
+		node.collapsed = collapsed[node.path]
+	}
+	a.rows = flatten(a.tree, 0, nil)
+	a.treeCursor = min(a.treeCursor, max(len(a.rows)-1, 0))
+	a.treeLoaded = time.Now()
+
+	return nil
+}
+
+// poll reloads when the watcher has written new events.
+func (a *app) poll() {
+	latestID, err := a.db.LatestEventID()
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	if latestID == a.latestID {
+		return
+	}
+
+	// The event list follows every poll, the directory counts more slowly
+	if err := a.reload(time.Since(a.treeLoaded) >= treeRefresh); err != nil {
+		a.message = err.Error()
+	}
+}
+
+func (a *app) key(event *tcell.EventKey) bool {
+	a.message = ""
+	_, height := a.screen.Size()
+	page := max(a.listHeight(height)-1, 1)
+
+	switch event.Key() {
+	case tcell.KeyCtrlC:
+		return true
+	case tcell.KeyEscape:
+		a.directory, a.expression = "", ""
+		a.apply()
+	case tcell.KeyTab:
+		if a.showTree {
+			a.focus = 1 - a.focus
+		}
+	case tcell.KeyUp:
+		a.move(-1)
+	case tcell.KeyDown:
+		a.move(1)
+	case tcell.KeyPgUp:
+		a.move(-page)
+	case tcell.KeyPgDn:
+		a.move(page)
+	case tcell.KeyHome:
+		a.move(-len(a.events) - len(a.rows))
+	case tcell.KeyEnd:
+		a.move(len(a.events) + len(a.rows))
+	case tcell.KeyLeft:
+		a.collapse(true)
+	case tcell.KeyRight:
+		a.collapse(false)
+	case tcell.KeyEnter:
+		if a.focus == paneTree && a.treeCursor < len(a.rows) {
+			a.directory = a.rows[a.treeCursor].path
+			a.focus = paneList
+			a.follow = true
+			a.apply()
+		}
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'q':
+			return true
+		case 'k':
+			a.move(-1)
+		case 'j':
+			a.move(1)
+		case 'g':
+			a.move(-len(a.events) - len(a.rows))
+		case 'G':
+			a.move(len(a.events) + len(a.rows))
+		case '/':
+			a.editing = true
+			a.input = []rune(a.expression)
+		case 'f':
+			a.follow = !a.follow
+			if a.follow {
+				a.selected = max(len(a.events)-1, 0)
+			}
+		case 'r':
+			if err := a.reload(true); err != nil {
+				a.message = err.Error()
+			}
+		case 't':
+			a.showTree = !a.showTree
+			if !a.showTree {
+				a.focus = paneList
+			}
+		case 'u':
+			a.directory = ""
+			a.apply()
+		case ' ':
+			if a.focus == paneTree && a.treeCursor < len(a.rows) {
+				node := a.rows[a.treeCursor]
+				node.collapsed = !node.collapsed
+				a.rows = flatten(a.tree, 0, nil)
+			}
+		}
+	}
+
+	return false
+}
+
+// editKey handles keys while the filter line is being edited.
+func (a *app) editKey(event *tcell.EventKey) {
+	switch event.Key() {
+	case tcell.KeyEscape, tcell.KeyCtrlC:
+		a.editing = false
+	case tcell.KeyEnter:
+		a.editing = false
+		previous := a.expression
+		a.expression = string(a.input)
+		if !a.apply() {
+			a.expression = previous
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(a.input) > 0 {
+			a.input = a.input[:len(a.input)-1]
+		}
+	case tcell.KeyCtrlU:
+		a.input = a.input[:0]
+	case tcell.KeyRune:
+		a.input = append(a.input, event.Rune())
+	}
+}
+
+// apply reloads after the filter changed and reports whether it was valid.
+func (a *app) apply() bool {
+	if err := a.reload(true); err != nil {
+		a.message = err.Error()
+		return false
+	}
+	return true
+}
+
+func (a *app) move(delta int) {
+	if a.focus == paneTree {
+		a.treeCursor = clamp(a.treeCursor+delta, 0, len(a.rows)-1)
+		return
+	}
+
+	a.selected = clamp(a.selected+delta, 0, len(a.events)-1)
+	a.follow = a.selected == len(a.events)-1
+}
+
+func (a *app) collapse(collapsed bool) {
+	if a.focus != paneTree || a.treeCursor >= len(a.rows) {
+		return
+	}
+
+	node := a.rows[a.treeCursor]
+	if len(node.children) > 0 {
+		node.collapsed = collapsed
+		a.rows = flatten(a.tree, 0, nil)
+	}
+}
+
+func (a *app) listHeight(height int) int {
+	return max(height-detailLines-3, 1)
+}
+
+func (a *app) draw() {
+	a.screen.Clear()
+	width, height := a.screen.Size()
+
+	// Header
+	header := fmt.Sprintf(" fstimeline  %d events", len(a.events))
+	if a.directory != "" {
+		header += "  dir: " + a.directory
+	}
+	if a.expression != "" {
+		header += "  filter: " + a.expression
+	}
+	if a.follow {
+		header += "  [follow]"
+	}
+	fill(a.screen, 0, 0, width, styleHeader)
+	drawText(a.screen, 0, 0, width, styleHeader, header)
+
+	listX := 0
+	listHeight := a.listHeight(height)
+	if a.showTree && width > treeWidth*2 {
+		listX = treeWidth + 1
+		a.drawTree(0, 1, treeWidth, listHeight)
+		for y := 1; y <= listHeight; y++ {
+			a.screen.SetContent(treeWidth, y, '│', nil, styleDim)
+		}
+	}
+	a.drawList(listX, 1, width-listX, listHeight)
+
+	// Detail pane
+	y := listHeight + 1
+	fill(a.screen, 0, y, width, styleDim)
+	drawText(a.screen, 0, y, width, styleDim, strings.Repeat("─", width))
+	for i, line := range a.detail() {
+		if i >= detailLines {
+			break
+		}
+		drawText(a.screen, 1, y+1+i, width-1, styleDefault, line)
+	}
+
+	// Status or filter line
+	status := "↑↓ move  tab switch pane  enter pick dir  u all dirs  / filter  esc clear  f follow  t tree  q quit"
+	style := styleDim
+	switch {
+	case a.editing:
+		status = "/" + string(a.input)
+		style = styleDefault
+		a.screen.ShowCursor(len(a.input)+1, height-1)
+	case a.message != "":
+		// Query errors span several lines; the first one says what is wrong
+		status = strings.SplitN(a.message, "\n", 2)[0]
+		style = styleError
+	}
+	if !a.editing {
+		a.screen.HideCursor()
+	}
+	drawText(a.screen, 0, height-1, width, style, status)
+
+	a.screen.Show()
+}
+
+func (a *app) drawList(x, y, width, height int) {
+	if len(a.events) == 0 {
+		drawText(a.screen, x+1, y, width-1, styleDim, "No events found.")
+		return
+	}
+
+	if a.follow {
+		a.selected = len(a.events) - 1
+	}
+	a.offset = scroll(a.offset, a.selected, height, len(a.events))
+
+	for row := 0; row < height && a.offset+row < len(a.events); row++ {
+		i := a.offset + row
+		event := a.events[i]
+
+		style := styleDefault
+		if i == a.selected {
+			style = styleSelected
+			if a.focus != paneList {
+				style = style.Dim(true)
+			}
+			fill(a.screen, x, y+row, width, style)
+		}
+
+		col := x + 1
+		col += drawText(a.screen, col, y+row, width-1, style.Dim(i != a.selected), event.Timestamp.Format("01-02 15:04:05")) + 2
+		typeStyle := style
+		if i != a.selected {
+			typeStyle = style.Foreground(eventColors[event.EventType])
+		}
+		col += drawText(a.screen, col, y+row, x+width-col, typeStyle, fmt.Sprintf("%-7s", event.EventType)) + 1
+		drawText(a.screen, col, y+row, x+width-col, style, event.FilePath)
+	}
+}
+
+func (a *app) drawTree(x, y, width, height int) {
+	if len(a.rows) == 0 {
+		return
+	}
+	a.treeOffset = scroll(a.treeOffset, a.treeCursor, height, len(a.rows))
+
+	for row := 0; row < height && a.treeOffset+row < len(a.rows); row++ {
+		i := a.treeOffset + row
+		node := a.rows[i]
+
+		style := styleDefault
+		if node.path == a.directory {
+			style = style.Bold(true)
+		}
+		if i == a.treeCursor && a.focus == paneTree {
+			style = styleSelected
+			fill(a.screen, x, y+row, width, style)
+		}
+
+		marker := "  "
+		if len(node.children) > 0 {
+			marker = "▾ "
+			if node.collapsed {
+				marker = "▸ "
+			}
+		}
+		count := fmt.Sprint(node.count)
+		label := strings.Repeat("  ", node.depth) + marker + node.name
+		drawText(a.screen, x, y+row, width-len(count)-1, style, label)
+		drawText(a.screen, x+width-len(count), y+row, len(count), style.Dim(i != a.treeCursor), count)
+	}
+}
+
+// detail describes the selected event and summarizes its file's history.
+// The history is only traced again when the selection changes.
+func (a *app) detail() []string {
+	if len(a.events) == 0 {
+		return nil
+	}
+
+	event := a.events[a.selected]
+	if event.ID == a.detailID {
+		return a.detailText
+	}
+
+	size := "unknown"
+	if event.Size != nil {
+		size = fmt.Sprintf("%d bytes", *event.Size)
+	}
+	lines := []string{
+		fmt.Sprintf("%s  %s", event.EventType, event.FilePath),
+		fmt.Sprintf("Time: %s   Type: %s   Size: %s   ID: %d",
+			event.Timestamp.Format("2006-01-02 15:04:05.000"), event.FileType, size, event.ID),
+	}
+
+	steps, err := history.Trace(a.db, event.FilePath, a.options.Window)
+	switch {
+	case err != nil:
+		lines = append(lines, "History: "+err.Error())
+	case len(steps) > 0:
+		lines = append(lines, fmt.Sprintf("History (%d steps, no content snapshots are recorded for diffs):", len(steps)))
+		first := max(len(steps)-(detailLines-len(lines)), 0)
+		for _, step := range steps[first:] {
+			line := fmt.Sprintf("  %s  %-7s %s", step.Start.Format("01-02 15:04:05"), step.EventType, step.Path)
+			if step.RenamedTo != "" {
+				line += " → " + step.RenamedTo
+			}
+			if step.Count > 1 {
+				line += fmt.Sprintf("  ×%d", step.Count)
+			}
+			if step.Size != nil {
+				line += fmt.Sprintf("  %d bytes", *step.Size)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	a.detailID = event.ID
+	a.detailText = lines
+	return lines
+}
+
+// drawText writes text clipped to width and returns the columns used.
+func drawText(screen tcell.Screen, x, y, width int, style tcell.Style, text string) int {
+	col := 0
+	for _, r := range text {
+		if col >= width {
+			break
+		}
+		screen.SetContent(x+col, y, r, nil, style)
+		col++
+	}
+	return col
+}
+
+func fill(screen tcell.Screen, x, y, width int, style tcell.Style) {
+	for col := 0; col < width; col++ {
+		screen.SetContent(x+col, y, ' ', nil, style)
+	}
+}
+
+// scroll returns the offset that keeps cursor within a window of height
+// rows over total rows, without leaving empty rows at the end.
+func scroll(offset, cursor, height, total int) int {
+	offset = min(offset, max(total-height, 0))
+	if cursor < offset {
+		return cursor
+	}
+	if cursor >= offset+height {
+		return cursor - height + 1
+	}
+	return offset
+}
+
+func clamp(value, low, high int) int {
+	return max(low, min(value, high))
+}
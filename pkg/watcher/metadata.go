@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// inspect records the size and metadata of the files events are about. They
+// are left nil when a file no longer exists at its path. It runs on the
+// flusher as events are collected, keeping the stat calls off the event loop;
+// events replayed from the spool after a crash have no metadata.
+func (w *Watcher) inspect(events []*database.Event) {
+	for _, event := range events {
+		if event.EventType == "REMOVE" || event.EventType == "RENAME" {
+			continue
+		}
+
+		info, err := os.Lstat(event.FilePath)
+		if err != nil {
+			continue
+		}
+
+		size := info.Size()
+		event.Size = &size
+		fileMetadata(event, event.FilePath, info)
+	}
+}
+
+// Unix file type bits of st_mode.
+const (
+	modeDir     = 0o040000
+	modeRegular = 0o100000
+	modeSymlink = 0o120000
+	modeFIFO    = 0o010000
+	modeSocket  = 0o140000
+	modeChar    = 0o020000
+	modeBlock   = 0o060000
+)
+
+// unixMode converts a Go file mode to st_mode, for platforms that do not
+// report it.
+func unixMode(mode fs.FileMode) int64 {
+	bits := int64(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+
+	switch {
+	case mode.IsDir():
+		bits |= modeDir
+	case mode&fs.ModeSymlink != 0:
+		bits |= modeSymlink
+	case mode&fs.ModeNamedPipe != 0:
+		bits |= modeFIFO
+	case mode&fs.ModeSocket != 0:
+		bits |= modeSocket
+	case mode&fs.ModeCharDevice != 0:
+		bits |= modeChar
+	case mode&fs.ModeDevice != 0:
+		bits |= modeBlock
+	default:
+		bits |= modeRegular
+	}
+
+	return bits
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,27 @@
+package watcher
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+func fileMetadata(event *database.Event, path string, info fs.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		event.Mode = int64Ptr(unixMode(info.Mode()))
+		event.ModTime = timePtr(info.ModTime())
+		return
+	}
+
+	event.Inode = int64Ptr(int64(stat.Ino))
+	event.Mode = int64Ptr(int64(stat.Mode))
+	event.UID = int64Ptr(int64(stat.Uid))
+	event.GID = int64Ptr(int64(stat.Gid))
+	event.ModTime = timePtr(time.Unix(stat.Mtimespec.Unix()))
+	event.AccessTime = timePtr(time.Unix(stat.Atimespec.Unix()))
+	event.ChangeTime = timePtr(time.Unix(stat.Ctimespec.Unix()))
+	event.BirthTime = timePtr(time.Unix(stat.Birthtimespec.Unix()))
+}
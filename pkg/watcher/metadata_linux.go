@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+	"golang.org/x/sys/unix"
+)
+
+// fileMetadata uses statx for the birth time, falling back to what stat
+// reports on kernels and filesystems without it.
+func fileMetadata(event *database.Event, path string, info fs.FileInfo) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW,
+		unix.STATX_BASIC_STATS|unix.STATX_BTIME, &stx)
+	if err == nil {
+		event.Inode = int64Ptr(int64(stx.Ino))
+		event.Mode = int64Ptr(int64(stx.Mode))
+		event.UID = int64Ptr(int64(stx.Uid))
+		event.GID = int64Ptr(int64(stx.Gid))
+		event.ModTime = timePtr(statxTime(stx.Mtime))
+		event.AccessTime = timePtr(statxTime(stx.Atime))
+		event.ChangeTime = timePtr(statxTime(stx.Ctime))
+		if stx.Mask&unix.STATX_BTIME != 0 {
+			event.BirthTime = timePtr(statxTime(stx.Btime))
+		}
+		return
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		event.Mode = int64Ptr(unixMode(info.Mode()))
+		event.ModTime = timePtr(info.ModTime())
+		return
+	}
+
+	event.Inode = int64Ptr(int64(stat.Ino))
+	event.Mode = int64Ptr(int64(stat.Mode))
+	event.UID = int64Ptr(int64(stat.Uid))
+	event.GID = int64Ptr(int64(stat.Gid))
+	event.ModTime = timePtr(time.Unix(stat.Mtim.Unix()))
+	event.AccessTime = timePtr(time.Unix(stat.Atim.Unix()))
+	event.ChangeTime = timePtr(time.Unix(stat.Ctim.Unix()))
+}
+
+func statxTime(ts unix.StatxTimestamp) time.Time {
+	return time.Unix(ts.Sec, int64(ts.Nsec))
+}
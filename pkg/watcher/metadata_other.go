@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package watcher
+
+import (
+	"io/fs"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// fileMetadata records what every platform reports: the mode and
+// modification time.
+func fileMetadata(event *database.Event, path string, info fs.FileInfo) {
+	event.Mode = int64Ptr(unixMode(info.Mode()))
+	event.ModTime = timePtr(info.ModTime())
+}
@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+type OverflowPolicy string
+
+const (
+	// PolicyBlock stalls the event loop until the flusher makes room.
+	PolicyBlock OverflowPolicy = "block"
+	// PolicyDrop discards incoming events while the queue is full.
+	PolicyDrop OverflowPolicy = "drop"
+	// PolicySample lets one in every N incoming events displace the oldest
+	// queued event while the queue is full, and drops the rest.
+	PolicySample OverflowPolicy = "sample"
+	// PolicyCoalesce merges an incoming event into a queued event with the
+	// same path and type while the queue is full, and drops the rest.
+	PolicyCoalesce OverflowPolicy = "coalesce"
+)
+
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(s); policy {
+	case PolicyBlock, PolicyDrop, PolicySample, PolicyCoalesce:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy %q (use block, drop, sample or coalesce)", s)
+	}
+}
+
+// eventQueue is the bounded hand-off between the event loop and the flusher.
+type eventQueue struct {
+	mu         sync.Mutex
+	events     []*database.Event
+	capacity   int
+	policy     OverflowPolicy
+	sampleRate int
+	overflowed int
+
+	dropped   int64
+	coalesced int64
+
+	ready chan struct{}
+	space chan struct{}
+}
+
+func newEventQueue(capacity int, policy OverflowPolicy, sampleRate int) *eventQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if sampleRate < 1 {
+		sampleRate = 1
+	}
+
+	return &eventQueue{
+		events:     make([]*database.Event, 0, capacity),
+		capacity:   capacity,
+		policy:     policy,
+		sampleRate: sampleRate,
+		ready:      make(chan struct{}, 1),
+		space:      make(chan struct{}, 1),
+	}
+}
+
+// journal is told what the queue does with events. It is called under the
+// queue's lock, so drain never returns an event whose changes it has not
+// seen.
+type journal interface {
+	// accept is called with the events the queue takes, before they are
+	// queued.
+	accept(events []*database.Event)
+	// displace is called with a queued event the sample policy dropped.
+	displace(event *database.Event)
+	// update is called with a queued event the coalesce policy changed.
+	update(event *database.Event)
+}
+
+// push queues events, applying the overflow policy to those that do not fit,
+// and tells j about them.
+func (q *eventQueue) push(ctx context.Context, events []*database.Event, j journal) {
+	for len(events) > 0 {
+		q.mu.Lock()
+		if n := min(len(events), q.capacity-len(q.events)); n > 0 {
+			j.accept(events[:n])
+			q.events = append(q.events, events[:n]...)
+			events = events[n:]
+			q.mu.Unlock()
+			signal(q.ready)
+			continue
+		}
+
+		if q.policy != PolicyBlock {
+			for _, event := range events {
+				q.overflow(event, j)
+			}
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.space:
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.dropped += int64(len(events))
+			q.mu.Unlock()
+			return
+		}
+	}
+}
+
+// overflow applies the non-blocking policies to an event that does not fit.
+// The caller holds q.mu.
+func (q *eventQueue) overflow(event *database.Event, j journal) {
+	switch q.policy {
+	case PolicySample:
+		q.overflowed++
+		if q.overflowed%q.sampleRate == 0 {
+			j.accept([]*database.Event{event})
+			j.displace(q.events[0])
+			copy(q.events, q.events[1:])
+			q.events[len(q.events)-1] = event
+		}
+		q.dropped++
+
+	case PolicyCoalesce:
+		for i := len(q.events) - 1; i >= 0; i-- {
+			queued := q.events[i]
+			if queued.FilePath == event.FilePath && queued.EventType == event.EventType {
+				queued.Timestamp = event.Timestamp
+				j.update(queued)
+				q.coalesced++
+				return
+			}
+		}
+		q.dropped++
+
+	default:
+		q.dropped++
+	}
+}
+
+// drain empties the queue. rotate, if not nil, is called before the lock is
+// released, when the queue holds no event that has not been returned.
+func (q *eventQueue) drain(rotate func()) []*database.Event {
+	q.mu.Lock()
+	events := q.events
+	q.events = make([]*database.Event, 0, q.capacity)
+	q.overflowed = 0
+	if rotate != nil {
+		rotate()
+	}
+	q.mu.Unlock()
+
+	signal(q.space)
+	return events
+}
+
+func (q *eventQueue) counters() (dropped, coalesced int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped, q.coalesced
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+func queueEvents(n int) []*database.Event {
+	events := make([]*database.Event, n)
+	for i := range events {
+		events[i] = &database.Event{EventType: "WRITE", FilePath: fmt.Sprintf("/tmp/f%d", i%3)}
+	}
+	return events
+}
+
+// recorder is a journal that keeps what it is told.
+type recorder struct {
+	accepted           []*database.Event
+	displaced, updated int
+}
+
+func (r *recorder) accept(events []*database.Event) { r.accepted = append(r.accepted, events...) }
+func (r *recorder) displace(*database.Event)        { r.displaced++ }
+func (r *recorder) update(*database.Event)          { r.updated++ }
+
+func TestQueueAcceptsBeforeQueueing(t *testing.T) {
+	tests := []struct {
+		policy             OverflowPolicy
+		accepted, dropped  int
+		coalesced, drained int
+	}{
+		{PolicyDrop, 4, 6, 0, 4},
+		{PolicySample, 4 + 3, 6, 0, 4},
+		{PolicyCoalesce, 4, 0, 6, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			q := newEventQueue(4, tt.policy, 2)
+
+			r := &recorder{}
+			q.push(context.Background(), queueEvents(10), r)
+
+			drained := q.drain(nil)
+			dropped, coalesced := q.counters()
+			if len(r.accepted) != tt.accepted || int(dropped) != tt.dropped || int(coalesced) != tt.coalesced || len(drained) != tt.drained {
+				t.Errorf("accepted %d, dropped %d, coalesced %d, drained %d; want %d, %d, %d, %d",
+					len(r.accepted), dropped, coalesced, len(drained), tt.accepted, tt.dropped, tt.coalesced, tt.drained)
+			}
+			if r.displaced != len(r.accepted)-len(drained) || r.updated != int(coalesced) {
+				t.Errorf("journal told of %d displaced and %d updated events", r.displaced, r.updated)
+			}
+
+			seen := make(map[*database.Event]bool)
+			for _, event := range r.accepted {
+				seen[event] = true
+			}
+			for _, event := range drained {
+				if !seen[event] {
+					t.Errorf("drained an event that was not accepted: %+v", event)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueBlockWaitsForDrain(t *testing.T) {
+	q := newEventQueue(2, PolicyBlock, 1)
+
+	r := &recorder{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.push(context.Background(), queueEvents(5), r)
+	}()
+
+	var drained []*database.Event
+	for len(drained) < 5 {
+		<-q.ready
+		// Nothing accepted may be left behind when the spool is rotated
+		drained = append(drained, q.drain(func() {
+			if len(q.events) != 0 {
+				t.Errorf("queue holds %d events at rotation", len(q.events))
+			}
+		})...)
+	}
+	<-done
+
+	if len(r.accepted) != 5 || len(drained) != 5 {
+		t.Errorf("accepted %d, drained %d; want 5", len(r.accepted), len(drained))
+	}
+	if dropped, _ := q.counters(); dropped != 0 {
+		t.Errorf("block policy dropped %d events", dropped)
+	}
+}
+
+func TestQueueSpoolMatchesDrained(t *testing.T) {
+	for _, policy := range []OverflowPolicy{PolicySample, PolicyCoalesce} {
+		t.Run(string(policy), func(t *testing.T) {
+			spool, err := OpenSpool(filepath.Join(t.TempDir(), "spool"))
+			if err != nil {
+				t.Fatalf("OpenSpool: %v", err)
+			}
+			defer spool.Close()
+
+			events := queueEvents(10)
+			for i, event := range events {
+				event.Timestamp = time.Date(2026, 3, 14, 9, 0, i, 0, time.UTC)
+			}
+
+			q := newEventQueue(4, policy, 2)
+			q.push(context.Background(), events, spoolJournal{spool})
+
+			var segment string
+			drained := q.drain(func() {
+				segment, err = spool.Rotate()
+			})
+			if err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+
+			// A crash now must replay exactly what the flush would commit
+			replayed, err := readSegment(segment)
+			if err != nil {
+				t.Fatalf("readSegment: %v", err)
+			}
+			if got, want := describe(replayed), describe(drained); got != want {
+				t.Errorf("spool replays\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func describe(events []*database.Event) string {
+	var lines []string
+	for _, event := range events {
+		lines = append(lines, fmt.Sprintf("%s %s %s", event.EventType, event.FilePath, event.Timestamp.Format(time.TimeOnly)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,348 @@
+package watcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// Spool is an append-only on-disk log of events that have been accepted by
+// the watcher but not yet committed to the database. Every event is synced
+// to disk before it enters the queue, so a crash loses nothing that was
+// acknowledged.
+//
+// The active file lives at path. When the watcher flushes, the active file is
+// rotated into a segment (path.<unix-nano>) that is removed once the batch has
+// been committed. Segments left behind by a crash are replayed on startup.
+// Delivery is at-least-once: a crash between the commit and the removal
+// replays a batch that was already stored.
+//
+// Events the overflow policy later drops or coalesces are not rewritten;
+// instead a line recording the change is appended, and replay applies it.
+type Spool struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+	// appended is set when the active file has events.
+	appended bool
+	// index holds the position of each event in the active file, so that
+	// later changes to it can be recorded.
+	index map[*database.Event]int
+}
+
+// spoolEntry is a line of the spool: an event, or a change to the event at
+// position Drop or Update among the events of the same file.
+type spoolEntry struct {
+	*database.Event
+	Drop   *int `json:"spool_drop,omitempty"`
+	Update *int `json:"spool_update,omitempty"`
+}
+
+func OpenSpool(path string) (*Spool, error) {
+	s := &Spool{path: path, index: make(map[*database.Event]int)}
+
+	// Anything in the active file belongs to a previous run.
+	if _, err := os.Stat(path); err == nil {
+		if _, err := s.rotateFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Spool) openActive() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	s.file = file
+	return nil
+}
+
+// Append writes events with a single sync.
+func (s *Spool) Append(events []*database.Event) error {
+	var buf []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode spool entry: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(buf); err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.index[event] = len(s.index)
+	}
+
+	return nil
+}
+
+// Drop records that event, appended since the last rotation, was dropped
+// and will not be committed.
+func (s *Spool) Drop(event *database.Event) error {
+	return s.change(event, true)
+}
+
+// Update records the current state of event, appended since the last
+// rotation.
+func (s *Spool) Update(event *database.Event) error {
+	return s.change(event, false)
+}
+
+func (s *Spool) change(event *database.Event, drop bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	position, ok := s.index[event]
+	if !ok {
+		return nil
+	}
+
+	entry := spoolEntry{Event: event, Update: &position}
+	if drop {
+		entry = spoolEntry{Drop: &position}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode spool entry: %w", err)
+	}
+
+	return s.write(append(line, '\n'))
+}
+
+// write appends buf to the active file and syncs it. The caller holds s.mu.
+func (s *Spool) write(buf []byte) error {
+	if _, err := s.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool file: %w", err)
+	}
+	s.appended = true
+
+	return nil
+}
+
+// Rotate closes the active file, moves it aside as a segment and starts a new
+// active file. The returned segment must be passed to Discard once its events
+// have been committed. If no events were appended since the last rotation,
+// Rotate does nothing and returns "".
+func (s *Spool) Rotate() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.appended {
+		return "", nil
+	}
+
+	if err := s.file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close spool file: %w", err)
+	}
+
+	segment, err := s.rotateFile()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.openActive(); err != nil {
+		return "", err
+	}
+	s.appended = false
+	clear(s.index)
+
+	return segment, nil
+}
+
+func (s *Spool) rotateFile() (string, error) {
+	segment := fmt.Sprintf("%s.%d", s.path, time.Now().UnixNano())
+	if err := os.Rename(s.path, segment); err != nil {
+		return "", fmt.Errorf("failed to rotate spool file: %w", err)
+	}
+	return segment, nil
+}
+
+func (s *Spool) Discard(segment string) error {
+	if err := os.Remove(segment); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool segment: %w", err)
+	}
+	return nil
+}
+
+// Replay inserts the events of every pending segment into db, oldest first,
+// and removes each segment after its events are committed.
+func (s *Spool) Replay(db database.Store) (int, error) {
+	segments, err := s.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, segment := range segments {
+		events, err := readSegment(segment)
+		if err != nil {
+			return total, err
+		}
+
+		if len(events) > 0 {
+			if err := db.InsertEvents(events); err != nil {
+				return total, fmt.Errorf("failed to replay spool segment %s: %w", segment, err)
+			}
+			total += len(events)
+		}
+
+		if err := s.Discard(segment); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// segments lists the segments of the spool, oldest first. The directory is
+// read rather than globbed, since path may contain glob metacharacters.
+func (s *Spool) segments() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool segments: %w", err)
+	}
+
+	prefix := filepath.Base(s.path) + "."
+	type segment struct {
+		path  string
+		nanos int64
+	}
+	var found []segment
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if nanos, err := strconv.ParseInt(suffix, 10, 64); err == nil {
+			found = append(found, segment{filepath.Join(filepath.Dir(s.path), entry.Name()), nanos})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].nanos < found[j].nanos })
+
+	segments := make([]string, len(found))
+	for i, segment := range found {
+		segments[i] = segment.path
+	}
+	return segments, nil
+}
+
+func readSegment(segment string) ([]*database.Event, error) {
+	file, err := os.Open(segment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	// Dropped events are left nil until the end, keeping positions stable
+	var events []*database.Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry spoolEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn final write from a crash; everything before it is intact.
+			break
+		}
+
+		switch {
+		case entry.Drop != nil:
+			if *entry.Drop < len(events) {
+				events[*entry.Drop] = nil
+			}
+		case entry.Event == nil:
+			continue
+		case entry.Update != nil:
+			if *entry.Update < len(events) && events[*entry.Update] != nil {
+				entry.Event.ID = 0
+				events[*entry.Update] = entry.Event
+			}
+		default:
+			entry.Event.ID = 0
+			events = append(events, entry.Event)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spool segment: %w", err)
+	}
+
+	return slices.DeleteFunc(events, func(event *database.Event) bool { return event == nil }), nil
+}
+
+// spoolJournal keeps the spool in step with the queue: events are synced to
+// it before the queue takes them, so that no event that was accepted is lost
+// in a crash, and the overflow policy's changes to queued events are recorded
+// so that a replay gives what would have been committed.
+type spoolJournal struct {
+	spool *Spool
+}
+
+func (j spoolJournal) accept(events []*database.Event) {
+	if j.spool == nil {
+		return
+	}
+	if err := j.spool.Append(events); err != nil {
+		fmt.Printf("[ERROR] Failed to spool %d events: %v\n", len(events), err)
+	}
+}
+
+func (j spoolJournal) displace(event *database.Event) {
+	if j.spool == nil {
+		return
+	}
+	if err := j.spool.Drop(event); err != nil {
+		fmt.Printf("[ERROR] Failed to spool a dropped event: %v\n", err)
+	}
+}
+
+func (j spoolJournal) update(event *database.Event) {
+	if j.spool == nil {
+		return
+	}
+	if err := j.spool.Update(event); err != nil {
+		fmt.Printf("[ERROR] Failed to spool a coalesced event: %v\n", err)
+	}
+}
+
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	// An empty active file carries nothing worth replaying.
+	if info, err := os.Stat(s.path); err == nil && info.Size() == 0 {
+		return os.Remove(s.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+func openTestSpool(t *testing.T, path string) *Spool {
+	t.Helper()
+	spool, err := OpenSpool(path)
+	if err != nil {
+		t.Fatalf("OpenSpool: %v", err)
+	}
+	return spool
+}
+
+func appendEvents(t *testing.T, spool *Spool, events []*database.Event) {
+	t.Helper()
+	if err := spool.Append(events); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+}
+
+func TestSpoolRotateAndDiscard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fstimeline.spool")
+	spool := openTestSpool(t, path)
+	defer spool.Close()
+
+	if segment, err := spool.Rotate(); err != nil || segment != "" {
+		t.Fatalf("Rotate of an empty spool = %q, %v", segment, err)
+	}
+
+	appendEvents(t, spool, queueEvents(3))
+	segment, err := spool.Rotate()
+	if err != nil || segment == "" {
+		t.Fatalf("Rotate = %q, %v", segment, err)
+	}
+
+	events, err := readSegment(segment)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("segment holds %d events, %v; want 3", len(events), err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("active file after rotation: %v, %v", info, err)
+	}
+
+	if err := spool.Discard(segment); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if _, err := os.Stat(segment); !os.IsNotExist(err) {
+		t.Errorf("segment still there after Discard: %v", err)
+	}
+	if err := spool.Discard(segment); err != nil {
+		t.Errorf("second Discard: %v", err)
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	// Glob metacharacters in the path must not hide segments
+	dir := filepath.Join(t.TempDir(), "spool [1]")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "fstimeline*.spool")
+
+	// A crashed run leaves two segments and an active file with a torn tail
+	spool := openTestSpool(t, path)
+	appendEvents(t, spool, queueEvents(2))
+	if _, err := spool.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	appendEvents(t, spool, queueEvents(3))
+	if _, err := spool.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	appendEvents(t, spool, queueEvents(1))
+	spool.file.WriteString(`{"EventType":"WRITE","FilePath":"/tmp/to`)
+	spool.file.Close()
+
+	// Unrelated files next to the spool are left alone
+	other := filepath.Join(dir, "fstimeline*.spool.bak")
+	if err := os.WriteFile(other, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	spool = openTestSpool(t, path)
+	defer spool.Close()
+	replayed, err := spool.Replay(db)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed != 6 {
+		t.Errorf("replayed %d events, want 6", replayed)
+	}
+	if latest, err := db.LatestEventID(); err != nil || latest != 6 {
+		t.Errorf("LatestEventID = %d, %v; want 6", latest, err)
+	}
+
+	if segments, err := spool.segments(); err != nil || len(segments) != 0 {
+		t.Errorf("segments left after replay: %v, %v", segments, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+
+	// Everything was discarded, so a second start replays nothing
+	if replayed, err := spool.Replay(db); err != nil || replayed != 0 {
+		t.Errorf("second Replay = %d, %v", replayed, err)
+	}
+}
+
+func TestSpoolSegmentsOldestFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fstimeline.spool")
+	spool := openTestSpool(t, path)
+	defer spool.Close()
+
+	var want []string
+	for i := 1; i <= 3; i++ {
+		appendEvents(t, spool, queueEvents(i))
+		segment, err := spool.Rotate()
+		if err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		want = append(want, segment)
+	}
+
+	segments, err := spool.segments()
+	if err != nil {
+		t.Fatalf("segments: %v", err)
+	}
+	if len(segments) != 3 || segments[0] != want[0] || segments[1] != want[1] || segments[2] != want[2] {
+		t.Errorf("segments = %v, want %v", segments, want)
+	}
+}
@@ -2,10 +2,12 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BaseMax/go-fs-timeline/pkg/database"
@@ -14,14 +16,21 @@ import (
 
 type Watcher struct {
 	fsWatcher     *fsnotify.Watcher
-	db            *database.DB
+	db            database.Store
+	queue         *eventQueue
 	eventBuffer   []*database.Event
 	bufferMu      sync.Mutex
 	flushInterval time.Duration
 	maxBufferSize int
+	spool         *Spool
+	sinks         []*sinkWorker
+	sessionID     *int64
+
+	kernelOverflows int64
+	recorded        database.OverflowStats
 }
 
-func New(db *database.DB, flushInterval time.Duration, maxBufferSize int) (*Watcher, error) {
+func New(db database.Store, flushInterval time.Duration, maxBufferSize int) (*Watcher, error) {
 	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fs watcher: %w", err)
@@ -30,38 +39,125 @@ func New(db *database.DB, flushInterval time.Duration, maxBufferSize int) (*Watc
 	return &Watcher{
 		fsWatcher:     fsWatcher,
 		db:            db,
+		queue:         newEventQueue(maxBufferSize*10, PolicyBlock, 1),
 		eventBuffer:   make([]*database.Event, 0, maxBufferSize),
 		flushInterval: flushInterval,
 		maxBufferSize: maxBufferSize,
 	}, nil
 }
 
+func (w *Watcher) SetSpool(spool *Spool) {
+	w.spool = spool
+}
+
+// SetSession links the events recorded from now on to a watcher session.
+func (w *Watcher) SetSession(id int64) {
+	w.sessionID = &id
+}
+
+// Sink receives each batch of events once it is committed, e.g. to forward
+// them to a log collector.
+type Sink interface {
+	Send(events []*database.Event) error
+	String() string
+}
+
+// sinkBacklog is how many batches can wait for a sink before further batches
+// to it are dropped.
+const sinkBacklog = 64
+
+// sinkWorker sends batches to a sink from its own goroutine, so a slow or
+// unreachable sink never holds up the database writer.
+type sinkWorker struct {
+	sink    Sink
+	batches chan []*database.Event
+	done    chan struct{}
+}
+
+func (s *sinkWorker) run() {
+	defer close(s.done)
+	for events := range s.batches {
+		if err := s.sink.Send(events); err != nil {
+			fmt.Printf("[ERROR] Failed to send %d events to %s: %v\n", len(events), s.sink, err)
+		}
+	}
+}
+
+// AddSink forwards committed events to sink as well. Batches the sink has
+// not taken by the time sinkBacklog more are waiting are dropped.
+func (w *Watcher) AddSink(sink Sink) {
+	worker := &sinkWorker{
+		sink:    sink,
+		batches: make(chan []*database.Event, sinkBacklog),
+		done:    make(chan struct{}),
+	}
+	go worker.run()
+	w.sinks = append(w.sinks, worker)
+}
+
+// SetQueue replaces the queue between the event loop and the flusher. It must
+// be called before Watch.
+func (w *Watcher) SetQueue(size int, policy OverflowPolicy, sampleRate int) {
+	w.queue = newEventQueue(size, policy, sampleRate)
+}
+
 func (w *Watcher) AddPath(path string) error {
 	return w.fsWatcher.Add(path)
 }
 
 func (w *Watcher) Watch(ctx context.Context) error {
-	ticker := time.NewTicker(w.flushInterval)
-	defer ticker.Stop()
+	flusherCtx, stopFlusher := context.WithCancel(context.Background())
+	flusherDone := make(chan struct{})
+	go func() {
+		defer close(flusherDone)
+		w.runFlusher(flusherCtx)
+	}()
+
+	defer func() {
+		stopFlusher()
+		<-flusherDone
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
-			// Flush remaining events
-			w.flush()
 			return nil
 
 		case event, ok := <-w.fsWatcher.Events:
 			if !ok {
 				return nil
 			}
-			w.handleEvent(event)
+			w.handleEvents(ctx, w.pendingEvents(event))
 
 		case err, ok := <-w.fsWatcher.Errors:
 			if !ok {
 				return nil
 			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				atomic.AddInt64(&w.kernelOverflows, 1)
+			}
 			fmt.Printf("[ERROR] File system watcher error: %v\n", err)
+		}
+	}
+}
+
+// runFlusher moves events from the queue into the buffer and writes them to
+// the database, so a slow disk never stalls reading from fsnotify.
+func (w *Watcher) runFlusher(ctx context.Context) {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Flush remaining events
+			w.flush()
+			return
+
+		case <-w.queue.ready:
+			if w.collect() >= w.maxBufferSize {
+				w.flush()
+			}
 
 		case <-ticker.C:
 			w.flush()
@@ -69,48 +165,130 @@ func (w *Watcher) Watch(ctx context.Context) error {
 	}
 }
 
-func (w *Watcher) handleEvent(fsEvent fsnotify.Event) {
-	eventType := w.getEventType(fsEvent.Op)
-	fileName := filepath.Base(fsEvent.Name)
-	directory := filepath.Dir(fsEvent.Name)
-	fileType := w.getFileType(fileName)
+// collect moves queued events into the buffer and returns the buffer size.
+func (w *Watcher) collect() int {
+	events := w.queue.drain(nil)
+	w.inspect(events)
+
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+	w.eventBuffer = append(w.eventBuffer, events...)
+	return len(w.eventBuffer)
+}
 
-	event := &database.Event{
-		Timestamp: time.Now(),
-		EventType: eventType,
-		FilePath:  fsEvent.Name,
-		FileName:  fileName,
-		FileType:  fileType,
-		Directory: directory,
+// pendingEvents returns first and the events already waiting behind it, so
+// that they are spooled with a single sync.
+func (w *Watcher) pendingEvents(first fsnotify.Event) []fsnotify.Event {
+	pending := []fsnotify.Event{first}
+	for len(pending) < w.maxBufferSize {
+		select {
+		case fsEvent, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return pending
+			}
+			pending = append(pending, fsEvent)
+		default:
+			return pending
+		}
 	}
+	return pending
+}
 
-	w.bufferMu.Lock()
-	w.eventBuffer = append(w.eventBuffer, event)
-	shouldFlush := len(w.eventBuffer) >= w.maxBufferSize
-	w.bufferMu.Unlock()
+func (w *Watcher) handleEvents(ctx context.Context, fsEvents []fsnotify.Event) {
+	events := make([]*database.Event, len(fsEvents))
+	for i, fsEvent := range fsEvents {
+		fileName := filepath.Base(fsEvent.Name)
 
-	if shouldFlush {
-		w.flush()
+		events[i] = &database.Event{
+			Timestamp: time.Now(),
+			EventType: w.getEventType(fsEvent.Op),
+			FilePath:  fsEvent.Name,
+			FileName:  fileName,
+			FileType:  w.getFileType(fileName),
+			Directory: filepath.Dir(fsEvent.Name),
+			SessionID: w.sessionID,
+		}
 	}
+
+	w.queue.push(ctx, events, spoolJournal{w.spool})
 }
 
 func (w *Watcher) flush() {
+	w.recordOverflow()
+
+	// The spool is rotated as the queue is drained, so the segment holds
+	// exactly the events of this batch.
+	var segment string
+	queued := w.queue.drain(func() {
+		if w.spool == nil {
+			return
+		}
+		var err error
+		if segment, err = w.spool.Rotate(); err != nil {
+			fmt.Printf("[ERROR] Failed to rotate spool: %v\n", err)
+		}
+	})
+	w.inspect(queued)
+
 	w.bufferMu.Lock()
-	if len(w.eventBuffer) == 0 {
-		w.bufferMu.Unlock()
+	events := append(w.eventBuffer, queued...)
+	w.eventBuffer = make([]*database.Event, 0, w.maxBufferSize)
+	w.bufferMu.Unlock()
+
+	if len(events) == 0 {
 		return
 	}
 
-	events := make([]*database.Event, len(w.eventBuffer))
-	copy(events, w.eventBuffer)
-	w.eventBuffer = w.eventBuffer[:0]
-	w.bufferMu.Unlock()
-
 	if err := w.db.InsertEvents(events); err != nil {
+		// The spool segment is kept so the batch is replayed on next startup.
 		fmt.Printf("[ERROR] Failed to flush %d events to database: %v\n", len(events), err)
-	} else {
-		fmt.Printf("[INFO] Flushed %d events to database\n", len(events))
+		return
 	}
+
+	fmt.Printf("[INFO] Flushed %d events to database\n", len(events))
+
+	for _, worker := range w.sinks {
+		select {
+		case worker.batches <- events:
+		default:
+			fmt.Printf("[WARN] Dropped %d events for %s, which is falling behind\n", len(events), worker.sink)
+		}
+	}
+
+	if segment != "" {
+		if err := w.spool.Discard(segment); err != nil {
+			fmt.Printf("[ERROR] Failed to discard spool segment: %v\n", err)
+		}
+	}
+}
+
+// recordOverflow stores what was lost or merged since the last flush.
+func (w *Watcher) recordOverflow() {
+	dropped, coalesced := w.queue.counters()
+	kernel := atomic.LoadInt64(&w.kernelOverflows)
+
+	stats := &database.OverflowStats{
+		Timestamp:       time.Now(),
+		Policy:          string(w.queue.policy),
+		Dropped:         dropped - w.recorded.Dropped,
+		Coalesced:       coalesced - w.recorded.Coalesced,
+		KernelOverflows: kernel - w.recorded.KernelOverflows,
+	}
+	if stats.Dropped == 0 && stats.Coalesced == 0 && stats.KernelOverflows == 0 {
+		return
+	}
+
+	if err := w.db.InsertOverflowStats(stats); err != nil {
+		fmt.Printf("[ERROR] Failed to record overflow stats: %v\n", err)
+		return
+	}
+
+	fmt.Printf("[WARN] Queue overflow: %d dropped, %d coalesced, %d kernel overflows\n",
+		stats.Dropped, stats.Coalesced, stats.KernelOverflows)
+
+	w.recorded.Dropped = dropped
+	w.recorded.Coalesced = coalesced
+	w.recorded.KernelOverflows = kernel
 }
 
 func (w *Watcher) getEventType(op fsnotify.Op) string {
@@ -138,7 +316,15 @@ func (w *Watcher) getFileType(fileName string) string {
 	return strings.TrimPrefix(ext, ".")
 }
 
+// Close flushes the remaining events and waits for the sinks to send them.
 func (w *Watcher) Close() error {
 	w.flush()
+
+	for _, worker := range w.sinks {
+		close(worker.batches)
+		<-worker.done
+	}
+	w.sinks = nil
+
 	return w.fsWatcher.Close()
 }
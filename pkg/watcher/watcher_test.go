@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BaseMax/go-fs-timeline/pkg/database"
+)
+
+// blockingSink records batches, each only once release is closed.
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	batches [][]*database.Event
+}
+
+func (s *blockingSink) Send(events []*database.Event) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, events)
+	return nil
+}
+
+func (s *blockingSink) String() string {
+	return "blocking sink"
+}
+
+func TestFlushCommitsBeforeSinks(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer db.Close()
+
+	w, err := New(db, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	sink := &blockingSink{release: make(chan struct{})}
+	w.AddSink(sink)
+
+	for batch := 0; batch < 3; batch++ {
+		w.eventBuffer = append(w.eventBuffer, queueEvents(2)...)
+
+		// A stalled sink must not hold up the database
+		done := make(chan struct{})
+		go func() {
+			w.flush()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("flush waited for the sink")
+		}
+	}
+
+	if latest, err := db.LatestEventID(); err != nil || latest != 6 {
+		t.Fatalf("LatestEventID = %d, %v; want 6", latest, err)
+	}
+
+	close(sink.release)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if len(sink.batches) != 3 {
+		t.Fatalf("sink got %d batches, want 3", len(sink.batches))
+	}
+	var id int64
+	for _, batch := range sink.batches {
+		for _, event := range batch {
+			if event.ID <= id {
+				t.Errorf("event id %d after %d: ids not set from the database", event.ID, id)
+			}
+			id = event.ID
+		}
+	}
+}